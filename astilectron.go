@@ -1,9 +1,11 @@
 package astilectron
 
 import (
+	"errors"
 	"fmt"
 	"github.com/asticode/go-astikit"
 	"net"
+	"os"
 	"os/exec"
 	"runtime"
 	"time"
@@ -25,6 +27,11 @@ var (
 	}
 )
 
+// Errors
+var (
+	ErrDryRun = errors.New("astilectron: provisioning dry run is done, nothing has been executed")
+)
+
 // App event names
 const (
 	EventNameAppClose               = "app.close"
@@ -55,6 +62,7 @@ type Astilectron struct {
 	stderrWriter    *astikit.WriterAdapter
 	stdoutWriter    *astikit.WriterAdapter
 	supported       *Supported
+	switches        []string
 	worker          *astikit.Worker
 	writer          *writer
 }
@@ -69,9 +77,14 @@ type Options struct {
 	BaseDirectoryPath  string
 	DataDirectoryPath  string
 	ElectronSwitches   []string
+	Headless           *HeadlessOptions   // If set, electron runs without a display, for instance in CI
+	LockfilePath       string             // Defaults to astilectron.lock in the base directory, if it exists
+	Preflight          bool               // If true, Start runs Preflight before executing electron and fails if it reports errors
+	Provisioner        ProvisionerOptions // Only used by the default provisioner
 	SingleInstance     bool
-	SkipSetup          bool // If true, the user must handle provisioning and executing astilectron.
-	TCPPort            *int // The port to listen on.
+	SkipSetup          bool                   // If true, the user must handle provisioning and executing astilectron.
+	SystemElectron     *SystemElectronOptions // If set, an electron installed on the system is used when compatible
+	TCPPort            *int                   // The port to listen on.
 	VersionAstilectron string
 	VersionElectron    string
 }
@@ -89,13 +102,16 @@ func New(l astikit.StdLogger, o Options) (a *Astilectron, err error) {
 		return
 	}
 
-	if o.VersionAstilectron == "" {
-		o.VersionAstilectron = DefaultVersionAstilectron
-	}
-	if o.VersionElectron == "" {
-		o.VersionElectron = DefaultVersionElectron
+	// Create paths
+	var p *Paths
+	if p, err = newLockedPaths(runtime.GOOS, runtime.GOARCH, &o); err != nil {
+		err = fmt.Errorf("creating new paths failed: %w", err)
+		return
 	}
 
+	// Xvfb is only available on Linux, where it's needed by electron < 12 when there's no display server
+	o.Headless = o.Headless.forEnvironment(p.os, o.VersionElectron, os.Getenv("DISPLAY"))
+
 	// Init
 	a = &Astilectron{
 		dispatcher:  newDispatcher(),
@@ -104,14 +120,14 @@ func New(l astikit.StdLogger, o Options) (a *Astilectron, err error) {
 		identifier:  newIdentifier(),
 		l:           astikit.AdaptStdLogger(l),
 		options:     o,
-		provisioner: newDefaultProvisioner(l),
+		paths:       p,
+		provisioner: newDefaultProvisioner(l, o.Provisioner),
 		worker:      astikit.NewWorker(astikit.WorkerOptions{Logger: l}),
 	}
 
-	// Set paths
-	if a.paths, err = newPaths(runtime.GOOS, runtime.GOARCH, o); err != nil {
-		err = fmt.Errorf("creating new paths failed: %w", err)
-		return
+	// Headless
+	if o.Headless != nil {
+		a.switches = headlessSwitches(*o.Headless, p.os, o.VersionElectron)
 	}
 
 	// Add default listeners
@@ -173,9 +189,27 @@ func (a *Astilectron) Start() (err error) {
 
 	// Provision
 	if !a.options.SkipSetup {
+		if err = a.systemElectron(); err != nil {
+			return fmt.Errorf("looking for system electron failed: %w", err)
+		}
 		if err = a.provision(); err != nil {
 			return fmt.Errorf("provisioning failed: %w", err)
 		}
+		a.paths.resolveAppExecutable()
+
+		// Nothing has been provisioned, there's nothing to execute
+		// An error is returned so that callers don't wait for an app that will never start
+		if a.options.Provisioner.Mode == ProvisionModeDryRun {
+			a.l.Debug("Provisioning dry run is done, not executing")
+			return ErrDryRun
+		}
+
+		// Preflight
+		if a.options.Preflight {
+			if err = a.preflight(); err != nil {
+				return fmt.Errorf("preflight failed: %w", err)
+			}
+		}
 	}
 
 	// Unfortunately communicating with Electron through stdin/stdout doesn't work on Windows so all communications
@@ -201,6 +235,70 @@ func (a *Astilectron) provision() error {
 	return a.provisioner.Provision(a.worker.Context(), a.options.AppName, runtime.GOOS, runtime.GOARCH, a.options.VersionAstilectron, a.options.VersionElectron, *a.paths)
 }
 
+// Preflight checks whether electron can start in the current environment, with the switches it will be executed with
+func (a *Astilectron) Preflight() PreflightReport {
+	return Preflight(a.worker.Context(), *a.paths, append(append([]string{}, a.switches...), a.options.ElectronSwitches...))
+}
+
+// preflight runs preflight checks, logs their issues and applies the switches they've chosen
+func (a *Astilectron) preflight() error {
+	a.l.Debug("Running preflight checks...")
+	r := a.Preflight()
+	if a.options.Headless != nil {
+		// There's no display server to choose switches for, and Xvfb is started later on
+		var is []PreflightIssue
+		for _, i := range r.Issues {
+			if i.Check != PreflightCheckDisplay {
+				is = append(is, i)
+			}
+		}
+		r.DisplayServer, r.Issues, r.Switches = "", is, nil
+	}
+	for _, i := range r.Issues {
+		if i.Severity == PreflightSeverityError {
+			a.l.Errorf("Preflight: %s", i)
+		} else {
+			a.l.Warnf("Preflight: %s", i)
+		}
+	}
+	if r.DisplayServer != "" {
+		a.l.Debugf("Preflight: display server is %s", r.DisplayServer)
+	}
+	a.switches = append(a.switches, r.Switches...)
+	return r.Err()
+}
+
+// systemElectron makes astilectron use an electron installed on the system if it is compatible
+func (a *Astilectron) systemElectron() (err error) {
+	// Nothing to do
+	if a.options.SystemElectron == nil {
+		return
+	}
+
+	// Custom electron must be compatible
+	var o = *a.options.SystemElectron
+	if a.options.CustomElectronPath != "" {
+		o.Paths = []string{a.options.CustomElectronPath}
+	}
+
+	// Find
+	a.l.Debug("Looking for system electron...")
+	var c SystemElectronCandidate
+	if c, err = FindSystemElectron(a.worker.Context(), runtime.GOOS, a.options.VersionElectron, o); err != nil {
+		var e SystemElectronError
+		if !errors.As(err, &e) || o.Required || a.options.CustomElectronPath != "" {
+			return
+		}
+		a.l.Infof("%s, provisioning electron %s instead", e, a.options.VersionElectron)
+		return nil
+	}
+
+	// Use system electron
+	a.l.Infof("Using system electron %s located at %s", c.Version, c.Path)
+	a.paths.useSystemElectron(c.Path)
+	return
+}
+
 // listenTCP creates a TCP server for astilectron to connect to
 // and listens to the first TCP connection coming its way (this should be Astilectron).
 func (a *Astilectron) listenTCP() (err error) {
@@ -291,7 +389,13 @@ func (a *Astilectron) execute() (err error) {
 	} else {
 		singleInstance = "false"
 	}
-	var cmd = exec.CommandContext(a.worker.Context(), a.paths.AppExecutable(), append([]string{a.paths.AstilectronApplication(), a.listener.Addr().String(), singleInstance}, a.options.ElectronSwitches...)...)
+	var args = []string{a.paths.AstilectronApplication(), a.listener.Addr().String(), singleInstance}
+
+	// On Linux, the WM_CLASS must match the StartupWMClass of the desktop entry
+	if runtime.GOOS == "linux" && a.options.AppName != "" {
+		args = append(args, "--class="+a.options.AppName)
+	}
+	var cmd = exec.CommandContext(a.worker.Context(), a.paths.AppExecutable(), append(args, mergeSwitches(a.switches, a.options.ElectronSwitches)...)...)
 	a.stderrWriter = astikit.NewWriterAdapter(astikit.WriterAdapterOptions{
 		Callback: func(i []byte) { a.l.Debugf("Stderr says: %s", i) },
 		Split:    []byte("\n"),
@@ -314,7 +418,11 @@ func (a *Astilectron) execute() (err error) {
 func (a *Astilectron) executeCmd(cmd *exec.Cmd) (err error) {
 	// Execute
 	var e Event
-	if e, err = synchronousFunc(a.worker.Context(), a, func() error { return a.executer(a.l, a, cmd) }, EventNameAppEventReady); err != nil {
+	var executer = a.executer
+	if a.options.Headless != nil && a.options.Headless.Xvfb && a.paths.os == "linux" {
+		executer = XvfbExecuter(*a.options.Headless, executer)
+	}
+	if e, err = synchronousFunc(a.worker.Context(), a, func() error { return executer(a.l, a, cmd) }, EventNameAppEventReady); err != nil {
 		err = fmt.Errorf("executer failed: %w", err)
 		return
 	}
@@ -424,7 +532,7 @@ func (a *Astilectron) NewMenu(i []*MenuItemOptions) *Menu {
 
 // NewWindow creates a new window
 func (a *Astilectron) NewWindow(url string, o *WindowOptions) (*Window, error) {
-	return newWindow(a.worker.Context(), a.l, a.options, a.Paths(), url, o, a.dispatcher, a.identifier, a.writer)
+	return a.newWindow(url, o)
 }
 
 // NewWindowInDisplay creates a new window in a specific display
@@ -440,7 +548,21 @@ func (a *Astilectron) NewWindowInDisplay(d *Display, url string, o *WindowOption
 	} else {
 		o.Y = astikit.IntPtr(d.Bounds().Y)
 	}
-	return newWindow(a.worker.Context(), a.l, a.options, a.Paths(), url, o, a.dispatcher, a.identifier, a.writer)
+	return a.newWindow(url, o)
+}
+
+// newWindow creates a new window and restores its state if asked to
+func (a *Astilectron) newWindow(url string, o *WindowOptions) (w *Window, err error) {
+	// Create window
+	if w, err = newWindow(a.worker.Context(), a.l, a.options, a.Paths(), url, o, a.dispatcher, a.identifier, a.writer); err != nil {
+		return
+	}
+
+	// Window state
+	if o.State != nil && o.State.Name != "" {
+		newWindowStateTracker(a.l, a.Paths(), w, a.displayPool.all)
+	}
+	return
 }
 
 // NewTray creates a new tray
@@ -40,6 +40,21 @@ func TestAstilectron_Provision(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAstilectron_StartDryRun(t *testing.T) {
+	// Init
+	var o = Options{
+		BaseDirectoryPath: mockedTempPath(),
+		Provisioner:       ProvisionerOptions{Mode: ProvisionModeDryRun},
+	}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	a, err := New(nil, o)
+	assert.NoError(t, err)
+	defer a.Close()
+
+	// Test start doesn't pretend the app is running
+	assert.Equal(t, ErrDryRun, a.Start())
+}
+
 func TestAstilectron_WatchNoAccept(t *testing.T) {
 	// Init
 	a, err := New(nil, Options{})
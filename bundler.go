@@ -0,0 +1,409 @@
+package astilectron
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Bundle paths, relative to the bundle root
+const (
+	BundleManifestPath     = "manifest.json"
+	BundleResourcesPath    = "resources"
+	bundleResourceIconName = "icon"
+)
+
+// BundlerOptions represents bundler options
+type BundlerOptions struct {
+	// Icon installed in the resources directory, use BundleManifest.AppIconDefaultPath as Options.AppIconDefaultPath
+	AppIconDefaultPath string
+	// Must be the same as Options.AppName
+	AppName string
+	// Defaults to runtime.GOARCH
+	Arch string
+	// Copied into the resources directory
+	AssetsDirectoryPath string
+	// The Go binary of the app, built for linux
+	BinaryPath string
+	// Pins versions, download URLs and digests. It is copied in the bundle so that the app uses it as well
+	LockfilePath string
+	// Used as modification time of every file of the bundle as well as installation time in the provision status so
+	// that bundles are reproducible. Defaults to now
+	ModTime time.Time
+	// If it ends with .tar.gz or .tgz, a tarball is created. Otherwise, a directory is created.
+	OutputPath string
+	// Defaults to the default provisioner
+	Provisioner        Provisioner
+	VersionAstilectron string
+	VersionElectron    string
+}
+
+// BundleManifest describes the content of a bundle
+type BundleManifest struct {
+	AppIconDefaultPath string                         `json:"appIconDefaultPath,omitempty"`
+	AppName            string                         `json:"appName,omitempty"`
+	Arch               string                         `json:"arch"`
+	Executable         string                         `json:"executable"`
+	Files              map[string]ProvisionStatusFile `json:"files"`
+	OS                 string                         `json:"os"`
+	VersionAstilectron string                         `json:"versionAstilectron"`
+	VersionElectron    string                         `json:"versionElectron"`
+}
+
+// Bundle creates a self-contained Linux distribution of an app: its Go binary and assets, as well as Electron and
+// Astilectron already provisioned in the vendor directory next to the binary, so that the app starts offline
+func Bundle(ctx context.Context, l astikit.StdLogger, o BundlerOptions) (m BundleManifest, err error) {
+	// Default options
+	if o.Arch == "" {
+		o.Arch = runtime.GOARCH
+	}
+	if o.ModTime.IsZero() {
+		o.ModTime = time.Now()
+	}
+	o.ModTime = o.ModTime.UTC().Truncate(time.Second)
+	if o.Provisioner == nil {
+		o.Provisioner = NewDefaultProvisioner(l, ProvisionerOptions{})
+	}
+
+	// Validate options
+	if o.BinaryPath == "" {
+		err = errors.New("no binary path provided")
+		return
+	}
+	if o.OutputPath == "" {
+		err = errors.New("no output path provided")
+		return
+	}
+
+	// Get absolute output path
+	if o.OutputPath, err = filepath.Abs(o.OutputPath); err != nil {
+		err = fmt.Errorf("getting absolute path of %s failed: %w", o.OutputPath, err)
+		return
+	}
+
+	// Make sure an existing output directory can be replaced
+	var tarball = strings.HasSuffix(o.OutputPath, ".tar.gz") || strings.HasSuffix(o.OutputPath, ".tgz")
+	if !tarball {
+		if err = checkBundleOutputDirectory(o.OutputPath); err != nil {
+			err = fmt.Errorf("checking output directory failed: %w", err)
+			return
+		}
+	}
+
+	// The bundle is created in a temp directory next to the output and moved into place once it's complete
+	var dir string
+	if err = os.MkdirAll(filepath.Dir(o.OutputPath), 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(o.OutputPath), err)
+		return
+	}
+	if dir, err = ioutil.TempDir(filepath.Dir(o.OutputPath), "astilectron-bundle-"); err != nil {
+		err = fmt.Errorf("creating temp dir failed: %w", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Chmod(dir, 0755); err != nil {
+		err = fmt.Errorf("chmoding %s failed: %w", dir, err)
+		return
+	}
+
+	// Create manifest
+	m = BundleManifest{
+		AppName:    o.AppName,
+		Arch:       o.Arch,
+		Executable: filepath.Base(o.BinaryPath),
+		OS:         "linux",
+	}
+
+	// Copy binary
+	if err = astikit.CopyFile(ctx, filepath.Join(dir, m.Executable), o.BinaryPath, astikit.LocalCopyFileFunc); err != nil {
+		err = fmt.Errorf("copying %s failed: %w", o.BinaryPath, err)
+		return
+	}
+
+	// Copy assets
+	if o.AssetsDirectoryPath != "" {
+		if err = astikit.CopyFile(ctx, filepath.Join(dir, BundleResourcesPath), o.AssetsDirectoryPath, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s failed: %w", o.AssetsDirectoryPath, err)
+			return
+		}
+	}
+
+	// Copy icon
+	if o.AppIconDefaultPath != "" {
+		m.AppIconDefaultPath = filepath.ToSlash(filepath.Join(BundleResourcesPath, bundleResourceIconName+filepath.Ext(o.AppIconDefaultPath)))
+		if err = astikit.CopyFile(ctx, filepath.Join(dir, filepath.FromSlash(m.AppIconDefaultPath)), o.AppIconDefaultPath, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s failed: %w", o.AppIconDefaultPath, err)
+			return
+		}
+	}
+
+	// Copy lockfile
+	if o.LockfilePath != "" {
+		if err = astikit.CopyFile(ctx, filepath.Join(dir, DefaultLockfileName), o.LockfilePath, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s failed: %w", o.LockfilePath, err)
+			return
+		}
+	}
+
+	// Provision
+	if m.VersionAstilectron, m.VersionElectron, err = bundleProvision(ctx, dir, o); err != nil {
+		err = fmt.Errorf("provisioning failed: %w", err)
+		return
+	}
+
+	// List files
+	if m.Files, err = listFiles(dir); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Write manifest
+	var b []byte
+	if b, err = json.MarshalIndent(m, "", "  "); err != nil {
+		err = fmt.Errorf("marshaling manifest failed: %w", err)
+		return
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, BundleManifestPath), append(b, '\n'), 0644); err != nil {
+		err = fmt.Errorf("writing manifest failed: %w", err)
+		return
+	}
+
+	// Create tarball
+	if tarball {
+		if err = bundleTarball(dir, o.OutputPath, LinuxExecutableName(o.AppName), o.ModTime); err != nil {
+			err = fmt.Errorf("creating tarball failed: %w", err)
+			return
+		}
+		return
+	}
+
+	// Update modification times
+	if err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink > 0 {
+			return nil
+		}
+		return os.Chtimes(path, o.ModTime, o.ModTime)
+	}); err != nil {
+		err = fmt.Errorf("updating modification times failed: %w", err)
+		return
+	}
+
+	// Replace the output directory
+	if err = os.RemoveAll(o.OutputPath); err != nil {
+		err = fmt.Errorf("removing %s failed: %w", o.OutputPath, err)
+		return
+	}
+	if err = os.Rename(dir, o.OutputPath); err != nil {
+		err = fmt.Errorf("renaming %s into %s failed: %w", dir, o.OutputPath, err)
+		return
+	}
+	return
+}
+
+// checkBundleOutputDirectory makes sure an existing output directory can be replaced, which is the case only if it's
+// empty or if it contains a bundle created previously, so that a directory such as the user's home is never wiped
+func checkBundleOutputDirectory(path string) (err error) {
+	// Stat
+	var fi os.FileInfo
+	if fi, err = os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("stating %s failed: %w", path, err)
+		return
+	} else if !fi.IsDir() {
+		err = fmt.Errorf("%s exists and is not a directory", path)
+		return
+	}
+
+	// Directory is empty
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(path); err != nil {
+		err = fmt.Errorf("reading directory %s failed: %w", path, err)
+		return
+	} else if len(fis) == 0 {
+		return
+	}
+
+	// Directory contains a bundle
+	var m BundleManifest
+	if b, errRead := ioutil.ReadFile(filepath.Join(path, BundleManifestPath)); errRead == nil && json.Unmarshal(b, &m) == nil && m.Executable != "" && m.Files != nil {
+		return
+	}
+	err = fmt.Errorf("%s is not empty and doesn't contain a bundle, refusing to replace it", path)
+	return
+}
+
+// bundleProvision provisions the vendor directory of a bundle and returns the provisioned versions
+func bundleProvision(ctx context.Context, dir string, o BundlerOptions) (versionAstilectron, versionElectron string, err error) {
+	// Create paths
+	var p *Paths
+	var po = Options{
+		AppName:            o.AppName,
+		BaseDirectoryPath:  dir,
+		DataDirectoryPath:  dir,
+		LockfilePath:       o.LockfilePath,
+		VersionAstilectron: o.VersionAstilectron,
+		VersionElectron:    o.VersionElectron,
+	}
+	if p, err = newLockedPaths("linux", o.Arch, &po); err != nil {
+		err = fmt.Errorf("creating paths failed: %w", err)
+		return
+	}
+	versionAstilectron, versionElectron = po.VersionAstilectron, po.VersionElectron
+
+	// Provision
+	if err = o.Provisioner.Provision(ctx, o.AppName, "linux", o.Arch, versionAstilectron, versionElectron, *p); err != nil {
+		err = fmt.Errorf("provisioning failed: %w", err)
+		return
+	}
+
+	// Remove the lock, in case the provisioner didn't
+	if err = os.Remove(p.ProvisionLock()); err != nil && !os.IsNotExist(err) {
+		err = fmt.Errorf("removing %s failed: %w", p.ProvisionLock(), err)
+		return
+	}
+	err = nil
+
+	// Read provision status
+	var s ProvisionStatus
+	if s, err = ReadProvisionStatus(*p); err != nil {
+		err = fmt.Errorf("reading provision status failed: %w", err)
+		return
+	}
+
+	// Make installation times reproducible
+	for _, ps := range append([]*ProvisionStatusPackage{s.Astilectron}, s.Electron[provisionStatusElectronKey("linux", o.Arch)]) {
+		if ps != nil {
+			ps.InstalledAt = &o.ModTime
+		}
+	}
+
+	// Write provision status
+	if err = writeProvisionStatus(*p, &s); err != nil {
+		err = fmt.Errorf("writing provision status failed: %w", err)
+		return
+	}
+	return
+}
+
+// bundleTarball creates a gzipped tarball out of a directory, with entries sorted and stripped of anything that
+// would make it differ between two builds
+func bundleTarball(dir, dst, root string, modTime time.Time) (err error) {
+	// List paths
+	var paths []string
+	if err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("walking through %s failed: %w", dir, err)
+	}
+	sort.Strings(paths)
+
+	// Create file
+	var f *os.File
+	if f, err = os.Create(dst); err != nil {
+		return fmt.Errorf("creating %s failed: %w", dst, err)
+	}
+	defer f.Close()
+
+	// Create writers
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	// Loop through paths
+	for _, path := range paths {
+		if err = bundleTarballEntry(tw, dir, path, root, modTime); err != nil {
+			return fmt.Errorf("adding %s failed: %w", path, err)
+		}
+	}
+
+	// Close
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("closing tar writer failed: %w", err)
+	}
+	if err = gw.Close(); err != nil {
+		return fmt.Errorf("closing gzip writer failed: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("closing %s failed: %w", dst, err)
+	}
+	return
+}
+
+// bundleTarballEntry adds a path to a tarball
+func bundleTarballEntry(tw *tar.Writer, dir, path, root string, modTime time.Time) (err error) {
+	// Stat
+	var fi os.FileInfo
+	if fi, err = os.Lstat(path); err != nil {
+		return fmt.Errorf("lstating %s failed: %w", path, err)
+	}
+
+	// Get name
+	var rel string
+	if rel, err = filepath.Rel(dir, path); err != nil {
+		return fmt.Errorf("getting relative path of %s failed: %w", path, err)
+	}
+
+	// Create header
+	var link string
+	if fi.Mode()&os.ModeSymlink > 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return fmt.Errorf("reading link %s failed: %w", path, err)
+		}
+	}
+	var h *tar.Header
+	if h, err = tar.FileInfoHeader(fi, link); err != nil {
+		return fmt.Errorf("creating header failed: %w", err)
+	}
+	h.Name = filepath.ToSlash(filepath.Join(root, rel))
+	if fi.IsDir() {
+		h.Name += "/"
+	}
+	h.AccessTime, h.ChangeTime, h.ModTime = time.Time{}, time.Time{}, modTime
+	h.Format = tar.FormatPAX
+	h.Gid, h.Gname, h.Uid, h.Uname = 0, "", 0, ""
+
+	// Write header
+	if err = tw.WriteHeader(h); err != nil {
+		return fmt.Errorf("writing header failed: %w", err)
+	}
+
+	// Only regular files have a content
+	if !fi.Mode().IsRegular() {
+		return
+	}
+
+	// Open file
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return fmt.Errorf("opening %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	// Copy
+	if _, err = io.Copy(tw, f); err != nil {
+		return fmt.Errorf("copying %s failed: %w", path, err)
+	}
+	return
+}
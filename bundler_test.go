@@ -0,0 +1,122 @@
+package astilectron
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundle(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	var a = filepath.Join(d, "assets")
+	assert.NoError(t, os.MkdirAll(a, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(a, "index.html"), []byte("index"), 0644))
+	var b = filepath.Join(d, "app")
+	assert.NoError(t, ioutil.WriteFile(b, []byte("binary"), 0755))
+	var o = BundlerOptions{
+		AppIconDefaultPath:  "testdata/provisioner/icon.png",
+		AppName:             "Test app",
+		Arch:                "amd64",
+		AssetsDirectoryPath: a,
+		BinaryPath:          b,
+		ModTime:             time.Unix(1600000000, 0),
+		OutputPath:          filepath.Join(d, "dist"),
+		Provisioner: NewReaderProvisioner(func() (io.ReadCloser, error) {
+			return os.Open("testdata/provisioner/astilectron/disembedder.zip")
+		}, func() (io.ReadCloser, error) {
+			return os.Open("testdata/provisioner/electron/linux/electron.zip")
+		}, nil, ProvisionerOptions{}),
+		VersionAstilectron: "0.35.1",
+		VersionElectron:    DefaultVersionElectron,
+	}
+
+	// Test directory
+	m, err := Bundle(context.Background(), nil, o)
+	assert.NoError(t, err)
+	assert.Equal(t, "resources/icon.png", m.AppIconDefaultPath)
+	assert.Equal(t, "app", m.Executable)
+	for _, n := range []string{"app", "resources/icon.png", "resources/index.html", "vendor/astilectron/main.js", "vendor/electron-linux-amd64/test-app", "vendor/status.json"} {
+		assert.Contains(t, m.Files, n)
+	}
+	assert.NotContains(t, m.Files, "vendor/provision.lock")
+	mb, err := ioutil.ReadFile(filepath.Join(o.OutputPath, BundleManifestPath))
+	assert.NoError(t, err)
+	var mr BundleManifest
+	assert.NoError(t, json.Unmarshal(mb, &mr))
+	assert.Equal(t, m, mr)
+	fi, err := os.Stat(filepath.Join(o.OutputPath, "app"))
+	assert.NoError(t, err)
+	assert.Equal(t, o.ModTime.Unix(), fi.ModTime().Unix())
+	p, err := newPaths("linux", "amd64", Options{AppName: o.AppName, BaseDirectoryPath: o.OutputPath, VersionAstilectron: o.VersionAstilectron, VersionElectron: o.VersionElectron})
+	assert.NoError(t, err)
+	s, err := ReadProvisionStatus(*p)
+	assert.NoError(t, err)
+	if assert.NotNil(t, s.Astilectron) && assert.NotNil(t, s.Astilectron.InstalledAt) {
+		assert.Equal(t, o.ModTime.Unix(), s.Astilectron.InstalledAt.Unix())
+	}
+	v, err := VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.True(t, v.Valid())
+
+	// Test a previous bundle is replaced
+	_, err = Bundle(context.Background(), nil, o)
+	assert.NoError(t, err)
+
+	// Test a directory that is not a bundle is not replaced
+	var od = o.OutputPath
+	o.OutputPath = a
+	_, err = Bundle(context.Background(), nil, o)
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(a, "index.html"))
+	assert.NoError(t, err)
+	o.OutputPath = od
+
+	// Test temp directories are cleaned up
+	fis, err := ioutil.ReadDir(d)
+	assert.NoError(t, err)
+	for _, fi := range fis {
+		assert.False(t, strings.HasPrefix(fi.Name(), "astilectron-bundle-"), fi.Name())
+	}
+
+	// Test tarball
+	var bs [][]byte
+	for _, n := range []string{"1.tar.gz", "2.tar.gz"} {
+		o.OutputPath = filepath.Join(d, n)
+		_, err = Bundle(context.Background(), nil, o)
+		assert.NoError(t, err)
+		b, err := ioutil.ReadFile(o.OutputPath)
+		assert.NoError(t, err)
+		bs = append(bs, b)
+	}
+	assert.Equal(t, bs[0], bs[1])
+	f, err := os.Open(o.OutputPath)
+	assert.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var ns []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		ns = append(ns, h.Name)
+	}
+	assert.Contains(t, ns, "test-app/app")
+	assert.Contains(t, ns, "test-app/manifest.json")
+	assert.Contains(t, ns, "test-app/vendor/electron-linux-amd64/test-app")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/asticode/go-astikit"
+	"github.com/asticode/go-astilectron"
+)
+
+// Flags
+var (
+	appIconDefaultPath  = flag.String("app-icon-default", "", "the default app icon path")
+	appName             = flag.String("app-name", "", "the app name, must be the same as the one in astilectron.Options")
+	arch                = flag.String("arch", runtime.GOARCH, "the arch to bundle")
+	assetsDirectoryPath = flag.String("assets", "", "the assets directory path")
+	binaryPath          = flag.String("b", "", "the path to the go binary, built for linux")
+	lockfilePath        = flag.String("lockfile", "", "the lockfile path")
+	outputPath          = flag.String("o", "", "the output path. If it ends with .tar.gz or .tgz, a tarball is created, otherwise a directory is created. An existing directory is only replaced if it is empty or contains a previous bundle")
+	verbose             = flag.Bool("v", false, "if true, logs are printed to stderr")
+	versionAstilectron  = flag.String("astilectron-version", "", "the astilectron version, defaults to the one pinned in the lockfile or to "+astilectron.DefaultVersionAstilectron)
+	versionElectron     = flag.String("electron-version", "", "the electron version, defaults to the one pinned in the lockfile or to "+astilectron.DefaultVersionElectron)
+)
+
+func main() {
+	// Parse flags
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\nThe SOURCE_DATE_EPOCH environment variable is used as modification time of the bundle's files if set.\n\nFlags:\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Create logger
+	var l astikit.StdLogger = log.New(ioutil.Discard, "", 0)
+	if *verbose {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	// Handle signals
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	// Run
+	if err := run(ctx, l); err != nil {
+		fmt.Fprintf(os.Stderr, "main: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, l astikit.StdLogger) (err error) {
+	// Get modification time
+	var modTime time.Time
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		var i int64
+		if i, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("parsing SOURCE_DATE_EPOCH %s failed: %w", v, err)
+		}
+		modTime = time.Unix(i, 0)
+	}
+
+	// Bundle
+	var m astilectron.BundleManifest
+	if m, err = astilectron.Bundle(ctx, l, astilectron.BundlerOptions{
+		AppIconDefaultPath:  *appIconDefaultPath,
+		AppName:             *appName,
+		Arch:                *arch,
+		AssetsDirectoryPath: *assetsDirectoryPath,
+		BinaryPath:          *binaryPath,
+		LockfilePath:        *lockfilePath,
+		ModTime:             modTime,
+		OutputPath:          *outputPath,
+		VersionAstilectron:  *versionAstilectron,
+		VersionElectron:     *versionElectron,
+	}); err != nil {
+		return fmt.Errorf("bundling failed: %w", err)
+	}
+
+	// Print
+	fmt.Printf("bundled %d files into %s\n", len(m.Files), *outputPath)
+	return
+}
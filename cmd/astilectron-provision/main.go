@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/asticode/go-astikit"
+	"github.com/asticode/go-astilectron"
+)
+
+// Flags
+var (
+	appIconDarwinPath  = flag.String("app-icon-darwin", "", "the darwin app icon path")
+	appIconDefaultPath = flag.String("app-icon-default", "", "the default app icon path")
+	appName            = flag.String("app-name", "", "the app name")
+	arch               = flag.String("arch", runtime.GOARCH, "the arch to provision")
+	dataDirectory      = flag.String("d", "", "the data directory, defaults to the working directory")
+	lockTimeout        = flag.Duration("lock-timeout", 0, "how long to wait for the vendor directory lock")
+	lockfilePath       = flag.String("lockfile", "", "the lockfile path, defaults to astilectron.lock in the data directory if it exists")
+	mode               = flag.String("mode", astilectron.ProvisionModeDefault, "the provision mode: offline or dry-run")
+	operatingSystem    = flag.String("os", runtime.GOOS, "the os to provision")
+	platforms          = flag.String("platforms", "", "the comma separated <os>/<arch> platforms to pin in the lockfile, defaults to the ones already pinned or to the os and arch flags")
+	verbose            = flag.Bool("v", false, "if true, logs are printed to stderr")
+	verifyHashes       = flag.Bool("verify-hashes", false, "if true, hashes are verified on top of sizes")
+	versionAstilectron = flag.String("astilectron-version", "", "the astilectron version, defaults to the one pinned in the lockfile or to "+astilectron.DefaultVersionAstilectron)
+	versionElectron    = flag.String("electron-version", "", "the electron version, defaults to the one pinned in the lockfile or to "+astilectron.DefaultVersionElectron)
+)
+
+func main() {
+	// Parse flags
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [provision|status|verify|prune|lock]\n\nFlags:\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Create logger
+	var l astikit.StdLogger = log.New(ioutil.Discard, "", 0)
+	if *verbose {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	// Handle signals
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	// Run
+	if err := run(ctx, l, flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "main: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, l astikit.StdLogger, cmd string) (err error) {
+	// Validate the OS
+	if !astilectron.IsValidOS(*operatingSystem) {
+		return fmt.Errorf("OS %s is invalid", *operatingSystem)
+	}
+
+	// Get data directory
+	var d = *dataDirectory
+	if d == "" {
+		if d, err = os.Getwd(); err != nil {
+			return fmt.Errorf("getting working directory failed: %w", err)
+		}
+	}
+
+	// Lock
+	if cmd == "lock" {
+		return lock(ctx, l, d)
+	}
+
+	// Create paths
+	var p *astilectron.Paths
+	if p, err = astilectron.NewPaths(*operatingSystem, *arch, astilectron.Options{
+		AppIconDarwinPath:  *appIconDarwinPath,
+		AppIconDefaultPath: *appIconDefaultPath,
+		AppName:            *appName,
+		BaseDirectoryPath:  d,
+		DataDirectoryPath:  d,
+		LockfilePath:       *lockfilePath,
+		VersionAstilectron: *versionAstilectron,
+		VersionElectron:    *versionElectron,
+	}); err != nil {
+		return fmt.Errorf("creating paths failed: %w", err)
+	}
+
+	// Switch on command
+	switch cmd {
+	case "", "provision":
+		// Provision
+		if err = astilectron.NewDefaultProvisioner(l, astilectron.ProvisionerOptions{
+			LockTimeout:  *lockTimeout,
+			Mode:         *mode,
+			OnPlan:       printPlan,
+			VerifyHashes: *verifyHashes,
+		}).Provision(ctx, *appName, *operatingSystem, *arch, p.VersionAstilectron(), p.VersionElectron(), *p); err != nil {
+			return fmt.Errorf("provisioning failed: %w", err)
+		}
+
+		// Nothing else to do in dry run mode
+		if *mode == astilectron.ProvisionModeDryRun {
+			return
+		}
+
+		// Verify
+		return verify(*p)
+	case "prune":
+		// Prune
+		var removed []string
+		if removed, err = astilectron.PruneProvisioning(ctx, l, *p, *lockTimeout); err != nil {
+			return fmt.Errorf("pruning failed: %w", err)
+		}
+
+		// Print
+		for _, r := range removed {
+			fmt.Printf("removed %s\n", r)
+		}
+	case "status":
+		// Read status
+		var s astilectron.ProvisionStatus
+		if s, err = astilectron.ReadProvisionStatus(*p); err != nil {
+			return fmt.Errorf("reading provision status failed: %w", err)
+		}
+
+		// Print
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err = e.Encode(s); err != nil {
+			return fmt.Errorf("json encoding provision status failed: %w", err)
+		}
+	case "verify":
+		return verify(*p)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+	return
+}
+
+func lock(ctx context.Context, l astikit.StdLogger, dataDirectory string) (err error) {
+	// Get path
+	var path = *lockfilePath
+	if path == "" {
+		path = filepath.Join(dataDirectory, astilectron.DefaultLockfileName)
+	}
+
+	// Read existing lockfile
+	var ps []astilectron.LockfilePlatform
+	if lf, err := astilectron.ReadLockfile(path); err == nil {
+		ps = lf.Platforms()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading lockfile failed: %w", err)
+	}
+
+	// Parse platforms
+	if *platforms != "" {
+		ps = []astilectron.LockfilePlatform{}
+		for _, v := range strings.Split(*platforms, ",") {
+			var p astilectron.LockfilePlatform
+			if p, err = astilectron.ParseLockfilePlatform(strings.TrimSpace(v)); err != nil {
+				return fmt.Errorf("parsing platform failed: %w", err)
+			}
+			ps = append(ps, p)
+		}
+	} else if len(ps) == 0 {
+		ps = []astilectron.LockfilePlatform{{Arch: *arch, OS: *operatingSystem}}
+	}
+
+	// Get versions
+	var vA, vE = *versionAstilectron, *versionElectron
+	if vA == "" {
+		vA = astilectron.DefaultVersionAstilectron
+	}
+	if vE == "" {
+		vE = astilectron.DefaultVersionElectron
+	}
+
+	// Generate
+	var lf astilectron.Lockfile
+	if lf, err = astilectron.GenerateLockfile(ctx, l, vA, vE, ps); err != nil {
+		return fmt.Errorf("generating lockfile failed: %w", err)
+	}
+
+	// Write
+	if err = astilectron.WriteLockfile(path, lf); err != nil {
+		return fmt.Errorf("writing lockfile failed: %w", err)
+	}
+	fmt.Printf("pinned astilectron %s and electron %s for %d platform(s) in %s\n", vA, vE, len(ps), path)
+	return
+}
+
+func printPlan(pl astilectron.ProvisionPlan) {
+	if pl.Empty() {
+		fmt.Println("nothing to provision")
+		return
+	}
+	for _, pp := range []*astilectron.ProvisionPlanPackage{pl.Astilectron, pl.Electron} {
+		if pp != nil {
+			fmt.Println(pp.String())
+		}
+	}
+}
+
+func verify(p astilectron.Paths) (err error) {
+	// Verify
+	var v astilectron.ProvisionVerification
+	if v, err = astilectron.VerifyProvisioning(p); err != nil {
+		return fmt.Errorf("verifying provisioning failed: %w", err)
+	}
+
+	// Print
+	var ok = true
+	for _, i := range []struct {
+		name string
+		v    astilectron.ProvisionPackageVerification
+		dir  string
+	}{
+		{name: "astilectron", v: v.Astilectron, dir: p.AstilectronDirectory()},
+		{name: "electron", v: v.Electron, dir: p.ElectronDirectory()},
+	} {
+		if i.v.Valid() {
+			fmt.Printf("%s: ok (%s)\n", i.name, i.dir)
+			continue
+		}
+		ok = false
+		if i.v.NotProvisioned {
+			fmt.Printf("%s: not provisioned (%s)\n", i.name, i.dir)
+			continue
+		}
+		fmt.Printf("%s: corrupted (%s)\n", i.name, i.dir)
+		if len(i.v.Missing) > 0 {
+			fmt.Printf("  missing: %s\n", strings.Join(i.v.Missing, ", "))
+		}
+		if len(i.v.Modified) > 0 {
+			fmt.Printf("  modified: %s\n", strings.Join(i.v.Modified, ", "))
+		}
+	}
+	if !ok {
+		return fmt.Errorf("provisioning of %s is invalid", p.VendorDirectory())
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package astilectron
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Headless defaults
+const (
+	DefaultXvfbPath    = "Xvfb"
+	DefaultXvfbScreen  = "1920x1080x24"
+	DefaultXvfbTimeout = 10 * time.Second
+)
+
+// HeadlessOptions represents options to run electron without a display, for instance in CI
+// GPU and sandbox are disabled. Windows are rendered offscreen unless Xvfb is used, in which case they are rendered
+// in the virtual display like they would in production.
+type HeadlessOptions struct {
+	// Linux only. If true, an Xvfb server is started for electron to render to
+	Xvfb bool
+	// Defaults to DefaultXvfbPath
+	XvfbPath string
+	// Formatted as "<width>x<height>x<depth>", defaults to DefaultXvfbScreen
+	XvfbScreen string
+	// Defaults to DefaultXvfbTimeout
+	XvfbTimeout time.Duration
+}
+
+// forEnvironment returns the options that apply to an environment: Xvfb is ignored on other OSes than Linux, in which
+// case windows are rendered offscreen, and it's enabled on Linux when electron can't run without a display server and
+// there's none
+func (o *HeadlessOptions) forEnvironment(os, versionElectron, display string) *HeadlessOptions {
+	switch {
+	case o == nil:
+		return o
+	case o.Xvfb && os != "linux":
+		c := *o
+		c.Xvfb = false
+		return &c
+	case !o.Xvfb && os == "linux" && display == "" && !headlessWithoutDisplay(versionElectron):
+		c := *o
+		c.Xvfb = true
+		return &c
+	}
+	return o
+}
+
+// headlessWithoutDisplay checks whether electron can run without any display server, which is the case as of
+// electron 12
+func headlessWithoutDisplay(versionElectron string) bool {
+	major, err := strconv.Atoi(strings.SplitN(versionElectron, ".", 2)[0])
+	return err == nil && major >= 12
+}
+
+// offscreen checks whether windows should be rendered offscreen
+func (o *HeadlessOptions) offscreen() bool {
+	return o != nil && !o.Xvfb
+}
+
+// headlessSwitches returns the switches electron should be executed with in headless mode
+func headlessSwitches(o HeadlessOptions, os, versionElectron string) (ss []string) {
+	ss = []string{"--disable-gpu", "--disable-dev-shm-usage", "--no-sandbox"}
+	if os == "linux" && !o.Xvfb && headlessWithoutDisplay(versionElectron) {
+		ss = append(ss, "--ozone-platform=headless")
+	}
+	return
+}
+
+// XvfbExecuter wraps an executer so that electron is executed in an Xvfb server started beforehand
+// Xvfb is stopped when astilectron stops.
+func XvfbExecuter(o HeadlessOptions, e Executer) Executer {
+	return func(l astikit.SeverityLogger, a *Astilectron, cmd *exec.Cmd) (err error) {
+		// Start xvfb
+		var x *exec.Cmd
+		var display string
+		if x, display, err = startXvfb(a.worker.Context(), l, o); err != nil {
+			err = fmt.Errorf("starting xvfb failed: %w", err)
+			return
+		}
+
+		// Xvfb is killed once the worker's context is cancelled
+		a.worker.NewTask().Do(func() {
+			err := x.Wait()
+			if a.worker.Context().Err() == nil {
+				l.Errorf("Xvfb has exited: %v", err)
+			} else {
+				l.Debug("Xvfb has exited")
+			}
+		})
+
+		// Update env
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		var env []string
+		for _, v := range cmd.Env {
+			if !strings.HasPrefix(v, "DISPLAY=") && !strings.HasPrefix(v, "WAYLAND_DISPLAY=") {
+				env = append(env, v)
+			}
+		}
+		cmd.Env = append(env, "DISPLAY="+display)
+
+		// Execute
+		l.Debugf("Executing electron in Xvfb display %s", display)
+		return e(l, a, cmd)
+	}
+}
+
+// startXvfb starts an Xvfb server on the first available display and returns it
+func startXvfb(ctx context.Context, l astikit.SeverityLogger, o HeadlessOptions) (cmd *exec.Cmd, display string, err error) {
+	// Default options
+	if o.XvfbPath == "" {
+		o.XvfbPath = DefaultXvfbPath
+	}
+	if o.XvfbScreen == "" {
+		o.XvfbScreen = DefaultXvfbScreen
+	}
+	if o.XvfbTimeout <= 0 {
+		o.XvfbTimeout = DefaultXvfbTimeout
+	}
+
+	// Create pipe
+	// Xvfb writes the display it has chosen in the file descriptor provided with -displayfd
+	var r, w *os.File
+	if r, w, err = os.Pipe(); err != nil {
+		err = fmt.Errorf("creating pipe failed: %w", err)
+		return
+	}
+	defer r.Close()
+
+	// Start
+	cmd = exec.CommandContext(ctx, o.XvfbPath, "-displayfd", "3", "-screen", "0", o.XvfbScreen, "-nolisten", "tcp")
+	cmd.ExtraFiles = []*os.File{w}
+	l.Debugf("Starting cmd %s", strings.Join(cmd.Args, " "))
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("%s not found, install Xvfb or provide a display server through DISPLAY: %w", o.XvfbPath, err)
+			return
+		}
+		err = fmt.Errorf("starting cmd %s failed: %w", strings.Join(cmd.Args, " "), err)
+		return
+	}
+
+	// Read display
+	var ch = make(chan string, 1)
+	go func() {
+		s, _ := bufio.NewReader(r).ReadString('\n')
+		ch <- strings.TrimSpace(s)
+	}()
+	var n string
+	select {
+	case n = <-ch:
+	case <-time.After(o.XvfbTimeout):
+	}
+	if _, errConv := strconv.Atoi(n); errConv != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		err = fmt.Errorf("xvfb didn't report its display within %s", o.XvfbTimeout)
+		return
+	}
+	display = ":" + n
+	return
+}
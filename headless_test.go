@@ -0,0 +1,85 @@
+package astilectron
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadlessSwitches(t *testing.T) {
+	assert.Equal(t, []string{"--disable-gpu", "--disable-dev-shm-usage", "--no-sandbox"}, headlessSwitches(HeadlessOptions{}, "linux", "11.4.3"))
+	assert.Equal(t, []string{"--disable-gpu", "--disable-dev-shm-usage", "--no-sandbox", "--ozone-platform=headless"}, headlessSwitches(HeadlessOptions{}, "linux", "12.0.0"))
+	assert.Equal(t, []string{"--disable-gpu", "--disable-dev-shm-usage", "--no-sandbox"}, headlessSwitches(HeadlessOptions{Xvfb: true}, "linux", "12.0.0"))
+	assert.Equal(t, []string{"--disable-gpu", "--disable-dev-shm-usage", "--no-sandbox"}, headlessSwitches(HeadlessOptions{}, "windows", "12.0.0"))
+}
+
+func TestHeadlessOptions_ForEnvironment(t *testing.T) {
+	assert.Nil(t, (*HeadlessOptions)(nil).forEnvironment("darwin", "11.4.3", ""))
+	assert.Equal(t, &HeadlessOptions{Xvfb: true}, (&HeadlessOptions{Xvfb: true}).forEnvironment("linux", "12.0.0", ":0"))
+	o := &HeadlessOptions{Xvfb: true, XvfbScreen: "800x600x24"}
+	assert.Equal(t, &HeadlessOptions{XvfbScreen: "800x600x24"}, o.forEnvironment("darwin", "11.4.3", ""))
+	assert.True(t, o.Xvfb)
+
+	// Electron < 12 needs a display server
+	o = &HeadlessOptions{XvfbScreen: "800x600x24"}
+	assert.Equal(t, &HeadlessOptions{Xvfb: true, XvfbScreen: "800x600x24"}, o.forEnvironment("linux", "11.4.3", ""))
+	assert.False(t, o.Xvfb)
+	assert.Equal(t, o, o.forEnvironment("linux", "11.4.3", ":0"))
+	assert.Equal(t, o, o.forEnvironment("linux", "12.0.0", ""))
+	assert.Equal(t, o, o.forEnvironment("windows", "11.4.3", ""))
+}
+
+func TestHeadlessWindow(t *testing.T) {
+	w, err := newWindow(context.Background(), &logger{}, Options{Headless: &HeadlessOptions{}}, Paths{}, "http://test.com", &WindowOptions{}, newDispatcher(), newIdentifier(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &WebPreferences{Offscreen: astikit.BoolPtr(true)}, w.o.WebPreferences)
+	w, err = newWindow(context.Background(), &logger{}, Options{Headless: &HeadlessOptions{Xvfb: true}}, Paths{}, "http://test.com", &WindowOptions{}, newDispatcher(), newIdentifier(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, w.o.WebPreferences)
+}
+
+func TestXvfbExecuter(t *testing.T) {
+	// Init
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts can't be executed on windows")
+	}
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	var o = HeadlessOptions{Xvfb: true, XvfbPath: filepath.Join(d, "Xvfb"), XvfbTimeout: time.Second}
+	assert.NoError(t, ioutil.WriteFile(o.XvfbPath, []byte("#!/bin/sh\necho 99 >&3\nexec sleep 10\n"), 0755))
+	a, err := New(nil, Options{BaseDirectoryPath: d})
+	assert.NoError(t, err)
+	defer a.Close()
+
+	// Success
+	var env []string
+	cmd := exec.Command("electron")
+	cmd.Env = []string{"DISPLAY=:0", "WAYLAND_DISPLAY=wayland-0", "KEY=value"}
+	err = XvfbExecuter(o, func(l astikit.SeverityLogger, a *Astilectron, cmd *exec.Cmd) error {
+		env = cmd.Env
+		return nil
+	})(a.l, a, cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"KEY=value", "DISPLAY=:99"}, env)
+
+	// Timeout
+	assert.NoError(t, ioutil.WriteFile(o.XvfbPath, []byte("#!/bin/sh\nexec sleep 10\n"), 0755))
+	o.XvfbTimeout = 100 * time.Millisecond
+	err = XvfbExecuter(o, func(l astikit.SeverityLogger, a *Astilectron, cmd *exec.Cmd) error { return nil })(a.l, a, exec.Command("electron"))
+	assert.Error(t, err)
+
+	// Xvfb is not installed
+	o.XvfbPath = "astilectron-missing-xvfb"
+	err = XvfbExecuter(o, func(l astikit.SeverityLogger, a *Astilectron, cmd *exec.Cmd) error { return nil })(a.l, a, exec.Command("electron"))
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+}
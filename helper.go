@@ -1,11 +1,16 @@
 package astilectron
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/asticode/go-astikit"
 )
@@ -99,7 +104,12 @@ func Disembed(ctx context.Context, l astikit.SeverityLogger, d Disembedder, src,
 
 // Unzip unzips a src into a dst.
 // Possible src formats are /path/to/zip.zip or /path/to/zip.zip/internal/path.
-func Unzip(ctx context.Context, l astikit.SeverityLogger, src, dst string) (err error) {
+// See UnzipReader for the guarantees enforced while unzipping.
+func Unzip(ctx context.Context, l astikit.SeverityLogger, src, dst string) error {
+	return unzip(ctx, l, src, dst, nil)
+}
+
+func unzip(ctx context.Context, l astikit.SeverityLogger, src, dst string, skip func(path string) bool) (err error) {
 	// Clean up on error
 	defer func(err *error) {
 		if *err != nil || ctx.Err() != nil {
@@ -108,15 +118,325 @@ func Unzip(ctx context.Context, l astikit.SeverityLogger, src, dst string) (err
 		}
 	}(&err)
 
-	// Unzipping
+	// Get external/internal paths
+	externalPath, internalPath := zipPaths(src)
+
+	// Open
 	l.Debugf("Unzipping %s into %s", src, dst)
-	if err = astikit.Unzip(ctx, dst, src); err != nil {
+	var f *os.File
+	if f, err = os.Open(externalPath); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", externalPath, err)
+		return
+	}
+	defer f.Close()
+
+	// Stat
+	var fi os.FileInfo
+	if fi, err = f.Stat(); err != nil {
+		err = fmt.Errorf("stating %s failed: %w", externalPath, err)
+		return
+	}
+
+	// Unzip
+	if err = unzipReader(ctx, l, f, fi.Size(), internalPath, dst, skip); err != nil {
 		err = fmt.Errorf("unzipping %s into %s failed: %w", src, dst, err)
 		return
 	}
 	return
 }
 
+// zipPaths splits a /path/to/zip.zip/internal/path src into its external and internal paths
+func zipPaths(src string) (external, internal string) {
+	if items := strings.Split(src, ".zip"); len(items) > 1 {
+		external = items[0] + ".zip"
+		internal = strings.TrimPrefix(strings.Join(items[1:], ".zip"), string(os.PathSeparator))
+		return
+	}
+	external = src
+	return
+}
+
+// UnzipReader unzips an archive read from r into a dst without writing the archive to disk.
+// If internalPath is not empty, only its content is unzipped.
+// Entries containing ".." or absolute paths as well as symlinks pointing outside of dst are rejected, so that a
+// malicious archive can't write outside of dst. Symlinks and executable bits are preserved whereas setuid, setgid
+// and sticky bits are dropped.
+func UnzipReader(ctx context.Context, l astikit.SeverityLogger, r io.ReaderAt, size int64, internalPath, dst string) error {
+	return unzipReader(ctx, l, r, size, internalPath, dst, nil)
+}
+
+// unzipReader unzips an archive read from r into a dst, skipping regular files whose destination path makes skip
+// return true
+func unzipReader(ctx context.Context, l astikit.SeverityLogger, r io.ReaderAt, size int64, internalPath, dst string, skip func(path string) bool) (err error) {
+	// Clean up on error
+	defer func(err *error) {
+		if *err != nil || ctx.Err() != nil {
+			l.Debugf("Removing %s...", dst)
+			os.RemoveAll(dst)
+		}
+	}(&err)
+
+	// Create reader
+	var zr *zip.Reader
+	if zr, err = zip.NewReader(r, size); err != nil {
+		return fmt.Errorf("creating zip reader failed: %w", err)
+	}
+
+	// Make sure the destination exists
+	if err = os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("mkdirall %s failed: %w", dst, err)
+	}
+
+	// We need the absolute path
+	if dst, err = filepath.Abs(dst); err != nil {
+		return fmt.Errorf("computing absolute path failed: %w", err)
+	}
+
+	// Validate entries before writing anything
+	internalPath = strings.Trim(filepath.ToSlash(internalPath), "/")
+	var dirs, files, symlinks = make(map[string]*zip.File), make(map[string]*zip.File), make(map[string]*zip.File)
+	for _, f := range zr.File {
+		// Validate internal path
+		var name = f.Name
+		if internalPath != "" {
+			if name != internalPath && !strings.HasPrefix(name, internalPath+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(strings.TrimPrefix(name, internalPath), "/")
+		}
+
+		// Get path
+		var p string
+		if p, err = zipEntryPath(dst, name); err != nil {
+			return fmt.Errorf("invalid entry %s: %w", f.Name, err)
+		}
+
+		// Check type
+		switch {
+		case f.Mode()&os.ModeSymlink != 0:
+			symlinks[p] = f
+		case f.Mode().IsDir():
+			dirs[p] = f
+		default:
+			files[p] = f
+		}
+	}
+
+	// Invalid internal path
+	if internalPath != "" && len(dirs) == 0 && len(files) == 0 && len(symlinks) == 0 {
+		return fmt.Errorf("content in archive does not match internal path %s", internalPath)
+	}
+
+	// Create dirs
+	for p := range dirs {
+		if err = os.MkdirAll(p, 0755); err != nil {
+			return fmt.Errorf("mkdirall %s failed: %w", p, err)
+		}
+	}
+
+	// Create files
+	for p, f := range files {
+		if skip != nil && skip(p) {
+			continue
+		}
+		if err = unzipFile(ctx, f, p); err != nil {
+			return fmt.Errorf("unzipping %s into %s failed: %w", f.Name, p, err)
+		}
+	}
+
+	// Symlinks are created last so that no file is written through them
+	for p, f := range symlinks {
+		if err = unzipSymlink(dst, f, p); err != nil {
+			return fmt.Errorf("unzipping %s into %s failed: %w", f.Name, p, err)
+		}
+	}
+
+	// A symlink pointing through symlinks created after it may have been validated against a path that has changed
+	// since, therefore symlinks are resolved once more now that they all exist
+	for p, f := range symlinks {
+		var rel, resolved string
+		if rel, err = filepath.Rel(dst, p); err != nil {
+			return fmt.Errorf("getting relative path of %s failed: %w", p, err)
+		}
+		if resolved, err = resolveSymlinks(dst, rel, 0); err != nil {
+			return fmt.Errorf("resolving symlink %s failed: %w", f.Name, err)
+		} else if !isPathInDirectory(resolved, dst) {
+			return fmt.Errorf("symlink %s points outside of %s", f.Name, dst)
+		}
+	}
+	return
+}
+
+// zipEntryPath returns the path of a zip entry in dst, making sure it doesn't escape dst
+func zipEntryPath(dst, name string) (p string, err error) {
+	// Zip entries are slash separated, but some archivers use backslashes
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	// Check absolute paths
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		err = errors.New("absolute paths are not allowed")
+		return
+	}
+
+	// Check parent directories
+	for _, item := range strings.Split(name, "/") {
+		if item == ".." {
+			err = errors.New("parent directories are not allowed")
+			return
+		}
+	}
+
+	// Check path is inside dst
+	p = filepath.Join(dst, filepath.FromSlash(name))
+	if !isPathInDirectory(p, dst) {
+		err = fmt.Errorf("%s is outside of %s", p, dst)
+		return
+	}
+	return
+}
+
+// isPathInDirectory checks whether a path is a directory or one of its descendants
+func isPathInDirectory(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) && !filepath.IsAbs(rel)
+}
+
+// zipEntryPerm returns the permissions a zip entry should be created with
+func zipEntryPerm(f *zip.File) os.FileMode {
+	// Archives created on systems without unix permissions don't have any
+	var perm = f.Mode().Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+
+	// Owner must always be able to read and write the file
+	return perm | 0600
+}
+
+// unzipFile unzips a regular file
+func unzipFile(ctx context.Context, f *zip.File, p string) (err error) {
+	// Open file reader
+	var fr io.ReadCloser
+	if fr, err = f.Open(); err != nil {
+		return fmt.Errorf("opening zip reader failed: %w", err)
+	}
+	defer fr.Close()
+
+	// Make sure the directory exists since directories are not always part of the archive
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(p), err)
+	}
+
+	// Create file
+	var fl *os.File
+	if fl, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+		return fmt.Errorf("opening %s failed: %w", p, err)
+	}
+	defer fl.Close()
+
+	// Copy
+	if _, err = astikit.Copy(ctx, fl, fr); err != nil {
+		return fmt.Errorf("copying into %s failed: %w", p, err)
+	}
+
+	// Set permissions explicitly since they're altered by the umask otherwise
+	if err = fl.Chmod(zipEntryPerm(f)); err != nil {
+		return fmt.Errorf("chmoding %s failed: %w", p, err)
+	}
+	return
+}
+
+// unzipSymlink unzips a symlink, making sure it doesn't point outside of dst
+func unzipSymlink(dst string, f *zip.File, p string) (err error) {
+	// Open file reader
+	var fr io.ReadCloser
+	if fr, err = f.Open(); err != nil {
+		return fmt.Errorf("opening zip reader failed: %w", err)
+	}
+	defer fr.Close()
+
+	// The symlink target is the content of the file
+	var b []byte
+	if b, err = ioutil.ReadAll(fr); err != nil {
+		return fmt.Errorf("reading symlink target failed: %w", err)
+	}
+	var target = filepath.FromSlash(string(b))
+
+	// Validate target
+	// Symlinks created earlier in the archive are resolved since checking the target lexically is not enough: with
+	// "d/l -> ..", "x -> d/l/.." points outside of dst
+	if filepath.IsAbs(target) || strings.HasPrefix(string(b), "/") {
+		return fmt.Errorf("symlink target %s is absolute", string(b))
+	}
+	var rel string
+	if rel, err = filepath.Rel(dst, filepath.Dir(p)); err != nil {
+		return fmt.Errorf("getting relative path of %s failed: %w", filepath.Dir(p), err)
+	}
+	var resolved string
+	if resolved, err = resolveSymlinks(dst, rel+string(os.PathSeparator)+target, 0); err != nil {
+		return fmt.Errorf("resolving symlink target %s failed: %w", string(b), err)
+	} else if !isPathInDirectory(resolved, dst) {
+		return fmt.Errorf("symlink target %s is outside of %s", string(b), dst)
+	}
+
+	// Make sure the directory exists
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(p), err)
+	}
+
+	// Create symlink
+	if err = os.Symlink(target, p); err != nil {
+		return fmt.Errorf("creating symlink to %s failed: %w", target, err)
+	}
+	return
+}
+
+// resolveSymlinks resolves a path relative to dir, following symlinks component by component the way the OS would,
+// even when their targets don't exist yet. Unlike filepath.Join, ".." is applied to the resolved path.
+func resolveSymlinks(dir, rel string, depth int) (p string, err error) {
+	// Avoid loops
+	if depth > 40 {
+		return "", errors.New("too many levels of symbolic links")
+	}
+
+	// Loop through components
+	p = dir
+	for _, c := range strings.Split(filepath.ToSlash(rel), "/") {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			p = filepath.Dir(p)
+			continue
+		}
+		p = filepath.Join(p, c)
+
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Lstat(p); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return "", fmt.Errorf("lstating %s failed: %w", p, err)
+		} else if fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		// Follow symlink
+		var target string
+		if target, err = os.Readlink(p); err != nil {
+			return "", fmt.Errorf("reading link %s failed: %w", p, err)
+		}
+		if filepath.IsAbs(target) {
+			p = filepath.Clean(target)
+		} else if p, err = resolveSymlinks(filepath.Dir(p), target, depth+1); err != nil {
+			return
+		}
+	}
+	return
+}
+
 // synchronousFunc executes a function, blocks until it has received a specific event or the context has been
 // cancelled and returns the corresponding event
 func synchronousFunc(parentCtx context.Context, l listenable, fn func() error, eventNameDone string) (e Event, err error) {
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -44,6 +45,8 @@ func (h *mockedHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		h.readFile(rw, "testdata/provisioner/electron/linux/electron.zip")
 	case "/provisioner/electron/windows":
 		h.readFile(rw, "testdata/provisioner/electron/windows/electron.zip")
+	case "/provisioner/electron/SHASUMS256.txt":
+		h.readFile(rw, "testdata/provisioner/electron/SHASUMS256.txt")
 	default:
 		rw.Write([]byte("body"))
 	}
@@ -110,6 +113,51 @@ func TestDisembed(t *testing.T) {
 	assert.Equal(t, "body", string(b))
 }
 
+func TestUnzip(t *testing.T) {
+	// Init
+	var dst = mockedTempPath()
+	defer os.RemoveAll(dst)
+
+	// Test modes and symlinks are preserved
+	err := Unzip(context.Background(), &logger{}, "testdata/provisioner/unzip/linux.zip", dst)
+	assert.NoError(t, err)
+	for p, m := range map[string]os.FileMode{
+		"electron":                   0755,
+		"chrome-sandbox":             0755,
+		"libffmpeg.so.1":             0644,
+		"resources/default_app.asar": 0644,
+	} {
+		fi, err := os.Lstat(filepath.Join(dst, p))
+		if assert.NoError(t, err, p) {
+			assert.Equal(t, m, fi.Mode(), p)
+		}
+	}
+	for p, target := range map[string]string{
+		"libffmpeg.so":   "libffmpeg.so.1",
+		"resources/link": filepath.Join("..", "libffmpeg.so.1"),
+	} {
+		v, err := os.Readlink(filepath.Join(dst, p))
+		if assert.NoError(t, err, p) {
+			assert.Equal(t, target, v, p)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dst, "resources", "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ffmpeg", string(b))
+
+	// Test malicious archives
+	for _, n := range []string{"absolute", "slip", "symlink", "symlink_absolute", "symlink_chain", "symlink_write"} {
+		var dst = filepath.Join(mockedTempPath(), "dst")
+		err = Unzip(context.Background(), &logger{}, "testdata/provisioner/unzip/"+n+".zip", dst)
+		assert.Error(t, err, n)
+		_, err = os.Stat(dst)
+		assert.True(t, os.IsNotExist(err), n)
+		_, err = os.Lstat(filepath.Join(filepath.Dir(dst), "evil"))
+		assert.True(t, os.IsNotExist(err), n)
+		os.RemoveAll(filepath.Dir(dst))
+	}
+}
+
 func TestPtr(t *testing.T) {
 	assert.Equal(t, true, *astikit.BoolPtr(true))
 	assert.Equal(t, 1, *astikit.IntPtr(1))
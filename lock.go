@@ -0,0 +1,243 @@
+package astilectron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Lock durations
+const (
+	DefaultProvisionLockTimeout = 5 * time.Minute
+	lockRefreshPeriod           = 5 * time.Second
+	lockRetryPeriod             = 100 * time.Millisecond
+	lockStaleAfter              = 30 * time.Second
+)
+
+// fileLock represents an inter-process lock based on the exclusive creation of a file
+// While the lock is held, its modification time is refreshed periodically so that a lock left behind by a crashed
+// process can be detected as stale and taken over. The file contains a token unique to its owner so that a process
+// never refreshes nor removes a lock it doesn't own.
+type fileLock struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	l      astikit.SeverityLogger
+	path   string
+	token  string
+}
+
+// lockFile blocks until the lock located at path has been acquired, the timeout has been reached or the context has
+// been cancelled
+func lockFile(ctx context.Context, l astikit.SeverityLogger, path string, timeout time.Duration) (fl *fileLock, err error) {
+	// Make sure the directory exists
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(path), err)
+		return
+	}
+
+	// Create timeout
+	if timeout <= 0 {
+		timeout = DefaultProvisionLockTimeout
+	}
+	var t = time.NewTimer(timeout)
+	defer t.Stop()
+
+	// Create token
+	var token string
+	if token, err = newLockToken(); err != nil {
+		err = fmt.Errorf("creating lock token failed: %w", err)
+		return
+	}
+
+	// Loop
+	var waiting bool
+	for {
+		// Try to create the file
+		var f *os.File
+		if f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); err == nil {
+			// Write token
+			_, err = f.WriteString(token)
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				err = fmt.Errorf("writing to %s failed: %w", path, err)
+				return
+			}
+			break
+		} else if !os.IsExist(err) {
+			err = fmt.Errorf("creating %s failed: %w", path, err)
+			return
+		}
+		err = nil
+
+		// Lock is stale
+		// The token is read before checking the modification time so that it's the stale lock's token: a lock created
+		// after it has been read can't be stale yet
+		if b, errRead := ioutil.ReadFile(path); errRead == nil {
+			if fi, errStat := os.Stat(path); errStat == nil && time.Since(fi.ModTime()) > lockStaleAfter {
+				if err = takeOverStaleLock(l, path, string(b)); err != nil {
+					err = fmt.Errorf("taking over stale lock %s failed: %w", path, err)
+					return
+				}
+				continue
+			}
+		}
+
+		// Log
+		if !waiting {
+			l.Debugf("Waiting for lock %s to be released...", path)
+			waiting = true
+		}
+
+		// Wait
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("waiting for lock %s failed: %w", path, ctx.Err())
+			return
+		case <-t.C:
+			err = fmt.Errorf("waiting for lock %s timed out after %s", path, timeout)
+			return
+		case <-time.After(lockRetryPeriod):
+		}
+	}
+
+	// Create lock
+	l.Debugf("Lock %s acquired", path)
+	fl = &fileLock{
+		done:  make(chan struct{}),
+		l:     l,
+		path:  path,
+		token: token,
+	}
+
+	// Refresh the lock in the background
+	var refreshCtx context.Context
+	refreshCtx, fl.cancel = context.WithCancel(context.Background())
+	go fl.refresh(refreshCtx)
+	return
+}
+
+// newLockToken creates a token unique to a lock owner
+func newLockToken() (t string, err error) {
+	var b = make([]byte, 8)
+	if _, err = rand.Read(b); err != nil {
+		err = fmt.Errorf("reading random bytes failed: %w", err)
+		return
+	}
+	t = strconv.Itoa(os.Getpid()) + "." + hex.EncodeToString(b)
+	return
+}
+
+// takeOverStaleLock removes a stale lock atomically
+// Several processes may find the same lock stale at once, therefore the lock is moved to a unique path first, which
+// only one of them can succeed at, and its token is checked again: if the lock has been released and acquired by
+// another process in the meantime, it's put back.
+func takeOverStaleLock(l astikit.SeverityLogger, path, token string) (err error) {
+	// Move the lock
+	var tmp = path + ".stale." + strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err = os.Rename(path, tmp); err != nil {
+		if os.IsNotExist(err) {
+			// Another process has taken the lock over
+			err = nil
+			return
+		}
+		err = fmt.Errorf("renaming %s into %s failed: %w", path, tmp, err)
+		return
+	}
+	defer os.Remove(tmp)
+
+	// Check the lock again
+	var b []byte
+	if b, err = ioutil.ReadFile(tmp); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", tmp, err)
+		return
+	}
+
+	// The lock is the stale one
+	if string(b) == token {
+		l.Debugf("Lock %s was stale, removed it", path)
+		return
+	}
+
+	// The lock has been acquired by another process in the meantime
+	// Linking fails if the path exists, unlike renaming which would overwrite a lock created since. In that case the
+	// lock can't be given back to its owner, which will notice it doesn't own it anymore.
+	if err = os.Link(tmp, path); err != nil {
+		if os.IsExist(err) {
+			err = fmt.Errorf("lock %s has been acquired again before it could be put back", path)
+			return
+		}
+		err = fmt.Errorf("linking %s to %s failed: %w", tmp, path, err)
+		return
+	}
+	return
+}
+
+// owned checks whether the lock file still contains the lock's token
+func (fl *fileLock) owned() (err error) {
+	var b []byte
+	if b, err = ioutil.ReadFile(fl.path); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", fl.path, err)
+		return
+	}
+	if string(b) != fl.token {
+		err = fmt.Errorf("lock %s has been taken over by another process", fl.path)
+		return
+	}
+	return
+}
+
+// refresh refreshes the lock modification time until the context is cancelled
+func (fl *fileLock) refresh(ctx context.Context) {
+	defer close(fl.done)
+	var t = time.NewTicker(lockRefreshPeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// Make sure the lock still belongs to us
+			if err := fl.owned(); err != nil {
+				fl.l.Error(fmt.Errorf("refreshing lock %s failed: %w", fl.path, err))
+				return
+			}
+
+			// Refresh
+			var n = time.Now()
+			if err := os.Chtimes(fl.path, n, n); err != nil {
+				fl.l.Error(fmt.Errorf("refreshing lock %s failed: %w", fl.path, err))
+			}
+		}
+	}
+}
+
+// unlock releases the lock
+func (fl *fileLock) unlock() (err error) {
+	fl.cancel()
+	<-fl.done
+
+	// Make sure the lock still belongs to us before removing it
+	if err = fl.owned(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return
+	}
+
+	// Remove
+	if err = os.Remove(fl.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s failed: %w", fl.path, err)
+	}
+	fl.l.Debugf("Lock %s released", fl.path)
+	return nil
+}
@@ -0,0 +1,122 @@
+package astilectron
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileLock(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	var p = filepath.Join(d, "test.lock")
+
+	// Test lock
+	fl, err := lockFile(context.Background(), &logger{}, p, time.Second)
+	assert.NoError(t, err)
+	_, err = os.Stat(p)
+	assert.NoError(t, err)
+
+	// Test timeout
+	_, err = lockFile(context.Background(), &logger{}, p, 200*time.Millisecond)
+	assert.Error(t, err)
+
+	// Test cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = lockFile(ctx, &logger{}, p, time.Second)
+	assert.Error(t, err)
+
+	// Test waiting
+	var c = make(chan error)
+	go func() {
+		fl2, err := lockFile(context.Background(), &logger{}, p, time.Second)
+		if err == nil {
+			err = fl2.unlock()
+		}
+		c <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, fl.unlock())
+	assert.NoError(t, <-c)
+	_, err = os.Stat(p)
+	assert.True(t, os.IsNotExist(err))
+
+	// Test a lock that has been taken over is not removed
+	fl, err = lockFile(context.Background(), &logger{}, p, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(p, []byte("2"), 0644))
+	assert.Error(t, fl.unlock())
+	b, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+	assert.NoError(t, os.Remove(p))
+
+	// Test stale lock
+	assert.NoError(t, ioutil.WriteFile(p, []byte("1"), 0644))
+	var mt = time.Now().Add(-2 * lockStaleAfter)
+	assert.NoError(t, os.Chtimes(p, mt, mt))
+	fl, err = lockFile(context.Background(), &logger{}, p, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, fl.unlock())
+
+	// Test stale lock taken over concurrently
+	assert.NoError(t, ioutil.WriteFile(p, []byte("1"), 0644))
+	assert.NoError(t, os.Chtimes(p, mt, mt))
+	var holders, maxHolders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fl, err := lockFile(context.Background(), &logger{}, p, 5*time.Second)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if n := atomic.AddInt32(&holders, 1); n > atomic.LoadInt32(&maxHolders) {
+				atomic.StoreInt32(&maxHolders, n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			assert.NoError(t, fl.unlock())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), maxHolders)
+}
+
+func TestTakeOverStaleLock(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	var p = filepath.Join(d, "test.lock")
+	assert.NoError(t, os.MkdirAll(d, 0755))
+
+	// Lock acquired by another process since it was found stale
+	assert.NoError(t, ioutil.WriteFile(p, []byte("2"), 0644))
+	assert.NoError(t, takeOverStaleLock(&logger{}, p, "1"))
+	b, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+
+	// Stale lock
+	assert.NoError(t, takeOverStaleLock(&logger{}, p, "2"))
+	_, err = os.Stat(p)
+	assert.True(t, os.IsNotExist(err))
+
+	// Lock already taken over
+	assert.NoError(t, takeOverStaleLock(&logger{}, p, "2"))
+
+	// No leftovers
+	fs, err := ioutil.ReadDir(d)
+	assert.NoError(t, err)
+	assert.Len(t, fs, 0)
+}
@@ -0,0 +1,378 @@
+package astilectron
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astikit"
+)
+
+// DefaultLockfileName is the name of the lockfile looked for in the base directory when no lockfile path is provided
+const DefaultLockfileName = "astilectron.lock"
+
+// Lockfile pins the astilectron and electron versions, download URLs and SHA-256 digests so that provisioning is
+// reproducible
+type Lockfile struct {
+	Astilectron LockfilePackage            `json:"astilectron"`
+	Electron    map[string]LockfilePackage `json:"electron"` // Indexed by "<os>-<arch>"
+}
+
+// LockfilePackage represents a package pinned in a lockfile
+// Archives generated on the fly, such as GitHub's source archives, are not byte-for-byte stable, therefore they're
+// pinned by the digest of their contents instead of the digest of the archive itself
+type LockfilePackage struct {
+	ContentsSHA256 string `json:"contentsSha256,omitempty"`
+	SHA256         string `json:"sha256,omitempty"`
+	URL            string `json:"url"`
+	Version        string `json:"version"`
+}
+
+// LockfilePlatform represents a platform electron is pinned for in a lockfile
+type LockfilePlatform struct {
+	Arch string
+	OS   string
+}
+
+// String implements the fmt.Stringer interface
+func (p LockfilePlatform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// ParseLockfilePlatform parses a platform formatted as "<os>/<arch>"
+func ParseLockfilePlatform(i string) (p LockfilePlatform, err error) {
+	var items = strings.Split(i, "/")
+	if len(items) != 2 || !IsValidOS(items[0]) || items[1] == "" {
+		err = fmt.Errorf("platform %s is invalid, it should be <os>/<arch>", i)
+		return
+	}
+	p.OS, p.Arch = items[0], items[1]
+	return
+}
+
+// Platforms returns the platforms electron is pinned for, sorted
+func (l Lockfile) Platforms() (ps []LockfilePlatform) {
+	for k := range l.Electron {
+		if i := strings.Index(k, "-"); i > 0 {
+			ps = append(ps, LockfilePlatform{Arch: k[i+1:], OS: k[:i]})
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].String() < ps[j].String() })
+	return
+}
+
+// ReadLockfile reads a lockfile
+func ReadLockfile(path string) (l Lockfile, err error) {
+	// Read
+	var b []byte
+	if b, err = ioutil.ReadFile(path); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", path, err)
+		return
+	}
+
+	// Unmarshal
+	if err = json.Unmarshal(b, &l); err != nil {
+		err = fmt.Errorf("unmarshaling %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+// WriteLockfile writes a lockfile
+func WriteLockfile(path string, l Lockfile) (err error) {
+	// Marshal
+	var b []byte
+	if b, err = json.MarshalIndent(l, "", "  "); err != nil {
+		err = fmt.Errorf("marshaling lockfile failed: %w", err)
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+// GenerateLockfile generates a lockfile pinning astilectron and electron for the provided platforms
+// The astilectron archive is generated by GitHub, therefore it's downloaded to compute the digest of its contents,
+// whereas electron digests are retrieved from the SHASUMS256.txt file of the electron release
+func GenerateLockfile(ctx context.Context, l astikit.StdLogger, versionAstilectron, versionElectron string, platforms []LockfilePlatform) (Lockfile, error) {
+	return generateLockfile(ctx, l, versionAstilectron, versionElectron, platforms, AstilectronDownloadSrc, ElectronDownloadSrc)
+}
+
+func generateLockfile(ctx context.Context, l astikit.StdLogger, versionAstilectron, versionElectron string, platforms []LockfilePlatform, srcAstilectron func(versionAstilectron string) string, srcElectron func(os, arch, versionElectron string) string) (lf Lockfile, err error) {
+	// Create downloader
+	var sl = astikit.AdaptStdLogger(l)
+	d := astikit.NewHTTPDownloader(astikit.HTTPDownloaderOptions{
+		Sender: astikit.HTTPSenderOptions{
+			Logger: l,
+		},
+	})
+	defer d.Close()
+
+	// Hash astilectron
+	lf.Astilectron = LockfilePackage{
+		URL:     srcAstilectron(versionAstilectron),
+		Version: versionAstilectron,
+	}
+	sl.Debugf("Hashing contents of %s", lf.Astilectron.URL)
+	buf := &bytes.Buffer{}
+	if err = d.DownloadInWriter(ctx, buf, astikit.HTTPDownloaderSrc{URL: lf.Astilectron.URL}); err != nil {
+		err = fmt.Errorf("downloading %s failed: %w", lf.Astilectron.URL, err)
+		return
+	}
+	var zr *zip.Reader
+	if zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		err = fmt.Errorf("creating zip reader for %s failed: %w", lf.Astilectron.URL, err)
+		return
+	}
+	if lf.Astilectron.ContentsSHA256, err = hashZipContents(zr); err != nil {
+		err = fmt.Errorf("hashing contents of %s failed: %w", lf.Astilectron.URL, err)
+		return
+	}
+
+	// Loop through platforms
+	lf.Electron = make(map[string]LockfilePackage)
+	var sums = make(map[string]map[string]string)
+	for _, p := range platforms {
+		// Get url
+		var u = srcElectron(p.OS, p.Arch, versionElectron)
+		var i = strings.LastIndex(u, "/")
+
+		// Get sums
+		var src = u[:i+1] + "SHASUMS256.txt"
+		s, ok := sums[src]
+		if !ok {
+			sl.Debugf("Downloading %s", src)
+			buf := &bytes.Buffer{}
+			if err = d.DownloadInWriter(ctx, buf, astikit.HTTPDownloaderSrc{URL: src}); err != nil {
+				err = fmt.Errorf("downloading %s failed: %w", src, err)
+				return
+			}
+			s = parseSHASums(buf.Bytes())
+			sums[src] = s
+		}
+
+		// Get sum
+		var sum string
+		if sum, ok = s[u[i+1:]]; !ok {
+			err = fmt.Errorf("no sum found for %s in %s", u[i+1:], src)
+			return
+		}
+
+		// Add package
+		lf.Electron[provisionStatusElectronKey(p.OS, p.Arch)] = LockfilePackage{
+			SHA256:  sum,
+			URL:     u,
+			Version: versionElectron,
+		}
+	}
+	return
+}
+
+// parseSHASums parses the output of sha256sum, indexing sums by file names
+func parseSHASums(b []byte) (s map[string]string) {
+	s = make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var fields = strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		s[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return
+}
+
+// newLockedPaths pins the options versions with the lockfile, if any, and creates paths whose download URLs and
+// digests are pinned as well
+func newLockedPaths(os, arch string, o *Options) (p *Paths, err error) {
+	// Read lockfile
+	var lf *Lockfile
+	if lf, err = readOptionsLockfile(*o); err != nil {
+		err = fmt.Errorf("reading lockfile failed: %w", err)
+		return
+	}
+
+	// Pin versions
+	if lf != nil {
+		if o.VersionAstilectron, err = lockfileVersion("astilectron", o.VersionAstilectron, lf.Astilectron.Version); err != nil {
+			return
+		}
+		if o.CustomElectronPath == "" {
+			if e, ok := lf.Electron[provisionStatusElectronKey(os, arch)]; ok {
+				if o.VersionElectron, err = lockfileVersion("electron", o.VersionElectron, e.Version); err != nil {
+					return
+				}
+			} else if !o.SkipSetup {
+				// Electron only needs to be pinned when it's provisioned
+				err = fmt.Errorf("electron is not pinned for %s/%s in the lockfile", os, arch)
+				return
+			}
+		}
+	}
+
+	// Default versions
+	if o.VersionAstilectron == "" {
+		o.VersionAstilectron = DefaultVersionAstilectron
+	}
+	if o.VersionElectron == "" {
+		o.VersionElectron = DefaultVersionElectron
+	}
+
+	// Create paths
+	if p, err = newPaths(os, arch, *o); err != nil {
+		err = fmt.Errorf("creating paths failed: %w", err)
+		return
+	}
+
+	// Pin paths
+	if lf != nil {
+		p.astilectronContentsSHA256 = lf.Astilectron.ContentsSHA256
+		p.astilectronDownloadSrc = lf.Astilectron.URL
+		p.astilectronDownloadSHA256 = lf.Astilectron.SHA256
+		if e, ok := lf.Electron[provisionStatusElectronKey(os, arch)]; ok && o.CustomElectronPath == "" {
+			p.electronDownloadSrc = e.URL
+			p.electronDownloadSHA256 = e.SHA256
+		}
+	}
+	return
+}
+
+// readOptionsLockfile reads the lockfile located at the lockfile path or, if none is provided, the one located in the
+// base directory if it exists
+func readOptionsLockfile(o Options) (lf *Lockfile, err error) {
+	// Get path
+	var path = o.LockfilePath
+	if path == "" {
+		// Get base directory
+		var p = &Paths{}
+		if err = p.initBaseDirectory(o.BaseDirectoryPath); err != nil {
+			err = fmt.Errorf("initializing base directory failed: %w", err)
+			return
+		}
+		path = filepath.Join(p.BaseDirectory(), DefaultLockfileName)
+
+		// Lockfile doesn't exist
+		if _, err = os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+			} else {
+				err = fmt.Errorf("stating %s failed: %w", path, err)
+			}
+			return
+		}
+	}
+
+	// Read
+	var l Lockfile
+	if l, err = ReadLockfile(path); err != nil {
+		return
+	}
+	lf = &l
+	return
+}
+
+// lockfileVersion returns the version pinned in the lockfile unless it conflicts with the one provided in the options
+func lockfileVersion(name, option, locked string) (string, error) {
+	if option != "" && option != locked {
+		return "", fmt.Errorf("%s version %s conflicts with version %s pinned in the lockfile", name, option, locked)
+	}
+	return locked, nil
+}
+
+// verifyArchive checks the SHA-256 digests of an archive and of its contents
+func verifyArchive(l astikit.SeverityLogger, path, sha256, contentsSHA256 string) (err error) {
+	// Verify archive
+	if sha256 != "" {
+		l.Debugf("Verifying digest of %s", path)
+		var h string
+		if h, err = hashFile(path); err != nil {
+			err = fmt.Errorf("hashing %s failed: %w", path, err)
+			return
+		}
+		if !strings.EqualFold(h, sha256) {
+			err = fmt.Errorf("digest of %s is %s, expected %s", path, h, sha256)
+			return
+		}
+	}
+
+	// Verify contents
+	if contentsSHA256 != "" {
+		l.Debugf("Verifying digest of the contents of %s", path)
+		var h string
+		if h, err = hashArchiveContents(path); err != nil {
+			err = fmt.Errorf("hashing contents of %s failed: %w", path, err)
+			return
+		}
+		if !strings.EqualFold(h, contentsSHA256) {
+			err = fmt.Errorf("digest of the contents of %s is %s, expected %s", path, h, contentsSHA256)
+			return
+		}
+	}
+	return
+}
+
+// hashArchiveContents returns the hex encoded SHA-256 of the contents of a zip archive
+func hashArchiveContents(path string) (h string, err error) {
+	// Open archive
+	var zr *zip.ReadCloser
+	if zr, err = zip.OpenReader(path); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", path, err)
+		return
+	}
+	defer zr.Close()
+
+	// Hash
+	return hashZipContents(&zr.Reader)
+}
+
+// hashZipContents returns the hex encoded SHA-256 of the contents of a zip archive
+// Only the names and contents of files are hashed, sorted by name, so that the digest doesn't depend on how the
+// archive has been compressed or on the order and modification times of its entries
+func hashZipContents(zr *zip.Reader) (h string, err error) {
+	// Sort files
+	var fs []*zip.File
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() {
+			fs = append(fs, f)
+		}
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Name < fs[j].Name })
+
+	// Loop through files
+	var hh = sha256.New()
+	for _, f := range fs {
+		// Open file
+		var rc io.ReadCloser
+		if rc, err = f.Open(); err != nil {
+			err = fmt.Errorf("opening %s failed: %w", f.Name, err)
+			return
+		}
+
+		// Hash file
+		var fh = sha256.New()
+		_, err = io.Copy(fh, rc)
+		rc.Close()
+		if err != nil {
+			err = fmt.Errorf("copying %s failed: %w", f.Name, err)
+			return
+		}
+		fmt.Fprintf(hh, "%s\x00%s\n", f.Name, hex.EncodeToString(fh.Sum(nil)))
+	}
+	h = hex.EncodeToString(hh.Sum(nil))
+	return
+}
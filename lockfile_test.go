@@ -0,0 +1,209 @@
+package astilectron
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testLockfileHashAstilectron = "2e7aee3c6b1dc8e4edc4ca2e4c8e2fa4b1d84a5a0f4f9a4bc9e3e2ab0a3e5d7f"
+	testLockfileHashLinux       = "f216dffd93a169a20e936530eda9820aa4462558e904e8f2641e159e30942932"
+	testLockfileHashWindows     = "8adec61d5955151ed809297f10616d6c9a123e62c41c6e734b2234c275239583"
+)
+
+func TestParseLockfilePlatform(t *testing.T) {
+	p, err := ParseLockfilePlatform("linux/amd64")
+	assert.NoError(t, err)
+	assert.Equal(t, LockfilePlatform{Arch: "amd64", OS: "linux"}, p)
+	assert.Equal(t, "linux/amd64", p.String())
+	_, err = ParseLockfilePlatform("linux")
+	assert.Error(t, err)
+	_, err = ParseLockfilePlatform("invalid/amd64")
+	assert.Error(t, err)
+}
+
+func TestGenerateLockfile(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	var s = httptest.NewServer(&mockedHandler{})
+	defer s.Close()
+	var srcAstilectron = func(versionAstilectron string) string { return s.URL + "/provisioner/astilectron" }
+	var srcElectron = func(os, arch, versionElectron string) string { return s.URL + "/provisioner/electron/" + os }
+	h, err := hashArchiveContents("testdata/provisioner/astilectron/astilectron.zip")
+	assert.NoError(t, err)
+
+	// Generate
+	lf, err := generateLockfile(context.Background(), nil, "1.0.0", "2.0.0", []LockfilePlatform{{Arch: "amd64", OS: "windows"}, {Arch: "amd64", OS: "linux"}}, srcAstilectron, srcElectron)
+	assert.NoError(t, err)
+	assert.Equal(t, Lockfile{
+		Astilectron: LockfilePackage{ContentsSHA256: h, URL: s.URL + "/provisioner/astilectron", Version: "1.0.0"},
+		Electron: map[string]LockfilePackage{
+			"linux-amd64":   {SHA256: testLockfileHashLinux, URL: s.URL + "/provisioner/electron/linux", Version: "2.0.0"},
+			"windows-amd64": {SHA256: testLockfileHashWindows, URL: s.URL + "/provisioner/electron/windows", Version: "2.0.0"},
+		},
+	}, lf)
+	assert.Equal(t, []LockfilePlatform{{Arch: "amd64", OS: "linux"}, {Arch: "amd64", OS: "windows"}}, lf.Platforms())
+
+	// Write + read
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	var p = filepath.Join(d, DefaultLockfileName)
+	assert.NoError(t, WriteLockfile(p, lf))
+	lfr, err := ReadLockfile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, lf, lfr)
+
+	// Missing sum
+	_, err = generateLockfile(context.Background(), nil, "1.0.0", "2.0.0", []LockfilePlatform{{Arch: "amd64", OS: "freebsd"}}, srcAstilectron, srcElectron)
+	assert.Error(t, err)
+}
+
+func TestNewLockedPaths(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	assert.NoError(t, WriteLockfile(filepath.Join(d, DefaultLockfileName), Lockfile{
+		Astilectron: LockfilePackage{SHA256: testLockfileHashAstilectron, URL: "astilectron-url", Version: "1.0.0"},
+		Electron: map[string]LockfilePackage{
+			"linux-amd64": {SHA256: testLockfileHashLinux, URL: "electron-url", Version: "2.0.0"},
+		},
+	}))
+
+	// No lockfile
+	o := Options{BaseDirectoryPath: filepath.Join(d, "invalid")}
+	p, err := newLockedPaths("linux", "amd64", &o)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultVersionAstilectron, o.VersionAstilectron)
+	assert.Equal(t, DefaultVersionElectron, p.VersionElectron())
+	assert.Equal(t, "", p.ElectronDownloadSHA256())
+
+	// Lockfile in base directory
+	o = Options{BaseDirectoryPath: d}
+	p, err = newLockedPaths("linux", "amd64", &o)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", o.VersionAstilectron)
+	assert.Equal(t, "2.0.0", o.VersionElectron)
+	assert.Equal(t, "1.0.0", p.VersionAstilectron())
+	assert.Equal(t, "2.0.0", p.VersionElectron())
+	assert.Equal(t, "astilectron-url", p.AstilectronDownloadSrc())
+	assert.Equal(t, testLockfileHashAstilectron, p.AstilectronDownloadSHA256())
+	assert.Equal(t, "electron-url", p.ElectronDownloadSrc())
+	assert.Equal(t, testLockfileHashLinux, p.ElectronDownloadSHA256())
+	assert.Equal(t, filepath.Join(p.VendorDirectory(), "electron-linux-amd64-v2.0.0.zip"), p.ElectronDownloadDst())
+
+	// Explicit lockfile
+	o = Options{BaseDirectoryPath: filepath.Join(d, "invalid"), LockfilePath: filepath.Join(d, DefaultLockfileName), VersionElectron: "2.0.0"}
+	_, err = newLockedPaths("linux", "amd64", &o)
+	assert.NoError(t, err)
+	o = Options{LockfilePath: filepath.Join(d, "invalid")}
+	_, err = newLockedPaths("linux", "amd64", &o)
+	assert.Error(t, err)
+
+	// Conflicting version
+	o = Options{BaseDirectoryPath: d, VersionElectron: "3.0.0"}
+	_, err = newLockedPaths("linux", "amd64", &o)
+	assert.Error(t, err)
+
+	// Platform not pinned
+	o = Options{BaseDirectoryPath: d}
+	_, err = newLockedPaths("windows", "amd64", &o)
+	assert.Error(t, err)
+	o = Options{BaseDirectoryPath: d, CustomElectronPath: "/path/to/electron"}
+	_, err = newLockedPaths("windows", "amd64", &o)
+	assert.NoError(t, err)
+	o = Options{BaseDirectoryPath: d, SkipSetup: true}
+	p, err = newLockedPaths("windows", "amd64", &o)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.VersionAstilectron())
+	assert.Equal(t, DefaultVersionElectron, p.VersionElectron())
+	assert.Equal(t, "", p.ElectronDownloadSHA256())
+}
+
+func TestHashArchiveContents(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	var write = func(path string, names []string, method uint16) {
+		f, err := os.Create(path)
+		assert.NoError(t, err)
+		defer f.Close()
+		zw := zip.NewWriter(f)
+		defer zw.Close()
+		for _, n := range names {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: n, Method: method, Modified: time.Now()})
+			assert.NoError(t, err)
+			if !strings.HasSuffix(n, "/") {
+				_, err = w.Write([]byte("content of " + n))
+				assert.NoError(t, err)
+			}
+		}
+	}
+
+	// Order of entries, compression, modification times and directories don't change the digest
+	write(filepath.Join(d, "1.zip"), []string{"a/", "a/1", "a/2"}, zip.Deflate)
+	write(filepath.Join(d, "2.zip"), []string{"a/2", "a/1"}, zip.Store)
+	h1, err := hashArchiveContents(filepath.Join(d, "1.zip"))
+	assert.NoError(t, err)
+	h2, err := hashArchiveContents(filepath.Join(d, "2.zip"))
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	// Contents do
+	write(filepath.Join(d, "3.zip"), []string{"a/1", "a/3"}, zip.Deflate)
+	h3, err := hashArchiveContents(filepath.Join(d, "3.zip"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+}
+
+func TestDefaultProvisioner_Lockfile(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	var s = httptest.NewServer(&mockedHandler{})
+	defer s.Close()
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+	h, err := hashArchiveContents("testdata/provisioner/astilectron/astilectron.zip")
+	assert.NoError(t, err)
+
+	// Digest mismatch
+	p.astilectronContentsSHA256 = testLockfileHashAstilectron
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.Error(t, err)
+	_, err = os.Stat(p.AstilectronDownloadDst())
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(p.AstilectronDirectory())
+	assert.True(t, os.IsNotExist(err))
+
+	// Provided archives are kept on digest mismatch
+	assert.NoError(t, os.MkdirAll(p.VendorDirectory(), 0755))
+	b, err := ioutil.ReadFile("testdata/provisioner/astilectron/astilectron.zip")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(p.AstilectronDownloadDst(), b, 0644))
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.Error(t, err)
+	_, err = os.Stat(p.AstilectronDownloadDst())
+	assert.NoError(t, err)
+	assert.NoError(t, os.Remove(p.AstilectronDownloadDst()))
+
+	// Digest match
+	p.astilectronContentsSHA256 = h
+	p.electronDownloadSHA256 = testLockfileHashLinux
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+}
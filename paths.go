@@ -2,73 +2,122 @@ package astilectron
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// Var
+var (
+	regexpLinuxExecutableName = regexp.MustCompile("[^a-z0-9._-]+")
+)
+
 // Paths represents the set of paths needed by Astilectron
 type Paths struct {
-	appExecutable          string
-	appIconDarwinSrc       string
-	appIconDefaultSrc      string
-	astilectronApplication string
-	astilectronDirectory   string
-	astilectronDownloadSrc string
-	astilectronDownloadDst string
-	astilectronUnzipSrc    string
-	baseDirectory          string
-	dataDirectory          string
-	electronDirectory      string
-	electronDownloadSrc    string
-	electronDownloadDst    string
-	electronUnzipSrc       string
-	provisionStatus        string
-	vendorDirectory        string
+	appExecutable             string
+	appExecutableFallback     string
+	appExecutableResolved     string
+	appIconDarwinSrc          string
+	appIconDefaultSrc         string
+	arch                      string
+	astilectronApplication    string
+	astilectronContentsSHA256 string
+	astilectronDirectory      string
+	astilectronDownloadSHA256 string
+	astilectronDownloadSrc    string
+	astilectronDownloadDst    string
+	astilectronUnzipSrc       string
+	baseDirectory             string
+	cacheDirectory            string
+	configDirectory           string
+	dataDirectory             string
+	electronDirectory         string
+	electronDownloadSHA256    string
+	electronDownloadSrc       string
+	electronDownloadDst       string
+	electronUnzipSrc          string
+	legacyVendorDirectory     string
+	logsDirectory             string
+	os                        string
+	provisionLock             string
+	provisionStatus           string
+	vendorDirectory           string
+	versionAstilectron        string
+	versionElectron           string
+}
+
+// NewPaths creates the paths Astilectron would use on the provided os and arch
+// Versions are pinned by the lockfile and default to DefaultVersionAstilectron and DefaultVersionElectron the same way
+// they do in New
+func NewPaths(os, arch string, o Options) (*Paths, error) {
+	return newLockedPaths(os, arch, &o)
 }
 
 // newPaths creates new paths
 func newPaths(os, arch string, o Options) (p *Paths, err error) {
 
 	// Init base directory path
-	p = &Paths{}
+	p = &Paths{
+		arch:               arch,
+		os:                 os,
+		versionAstilectron: o.VersionAstilectron,
+		versionElectron:    o.VersionElectron,
+	}
 	if err = p.initBaseDirectory(o.BaseDirectoryPath); err != nil {
 		err = fmt.Errorf("initializing base directory failed: %w", err)
 		return
 	}
 
 	// Init data directory path
-	if err = p.initDataDirectory(o.DataDirectoryPath, o.AppName); err != nil {
+	if err = p.initDataDirectory(o); err != nil {
 		err = fmt.Errorf("initializing data directory failed: %w", err)
 		return
 	}
 
 	// Init other paths
 	//!\\ Order matters
+	var resourcesDirectory = p.dataDirectory
+	if p.legacyVendorDirectory != "" {
+		// Resources are still shipped next to the executable when following the XDG specification
+		resourcesDirectory = p.baseDirectory
+	}
 	p.appIconDarwinSrc = o.AppIconDarwinPath
 	if len(p.appIconDarwinSrc) > 0 && !filepath.IsAbs(p.appIconDarwinSrc) {
-		p.appIconDarwinSrc = filepath.Join(p.dataDirectory, p.appIconDarwinSrc)
+		p.appIconDarwinSrc = filepath.Join(resourcesDirectory, p.appIconDarwinSrc)
 	}
 	p.appIconDefaultSrc = o.AppIconDefaultPath
 	if len(p.appIconDefaultSrc) > 0 && !filepath.IsAbs(p.appIconDefaultSrc) {
-		p.appIconDefaultSrc = filepath.Join(p.dataDirectory, p.appIconDefaultSrc)
+		p.appIconDefaultSrc = filepath.Join(resourcesDirectory, p.appIconDefaultSrc)
 	}
 	p.vendorDirectory = filepath.Join(p.dataDirectory, "vendor")
+	if p.cacheDirectory == "" {
+		p.cacheDirectory = p.vendorDirectory
+	}
+	if p.configDirectory == "" {
+		p.configDirectory = p.dataDirectory
+	}
+	if p.logsDirectory == "" {
+		p.logsDirectory = filepath.Join(p.dataDirectory, "logs")
+	}
+	p.provisionLock = filepath.Join(p.vendorDirectory, "provision.lock")
 	p.provisionStatus = filepath.Join(p.vendorDirectory, "status.json")
 	p.astilectronDirectory = filepath.Join(p.vendorDirectory, "astilectron")
 	p.astilectronApplication = filepath.Join(p.astilectronDirectory, "main.js")
 	p.astilectronDownloadSrc = AstilectronDownloadSrc(o.VersionAstilectron)
-	p.astilectronDownloadDst = filepath.Join(p.vendorDirectory, fmt.Sprintf("astilectron-v%s.zip", o.VersionAstilectron))
+	p.astilectronDownloadDst = filepath.Join(p.cacheDirectory, fmt.Sprintf("astilectron-v%s.zip", o.VersionAstilectron))
 	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, fmt.Sprintf("astilectron-%s", o.VersionAstilectron))
 	if o.CustomElectronPath == "" {
 		p.electronDirectory = filepath.Join(p.vendorDirectory, fmt.Sprintf("electron-%s-%s", os, arch))
 		p.electronDownloadSrc = ElectronDownloadSrc(os, arch, o.VersionElectron)
-		p.electronDownloadDst = filepath.Join(p.vendorDirectory, fmt.Sprintf("electron-%s-%s-v%s.zip", os, arch, o.VersionElectron))
+		p.electronDownloadDst = filepath.Join(p.cacheDirectory, fmt.Sprintf("electron-%s-%s-v%s.zip", os, arch, o.VersionElectron))
 		p.electronUnzipSrc = p.electronDownloadDst
 		p.initAppExecutable(os, o.AppName)
 	} else {
 		p.appExecutable = o.CustomElectronPath
 	}
+	p.resolveAppExecutable()
 	return
 }
 
@@ -94,12 +143,13 @@ func (p *Paths) initBaseDirectory(baseDirectoryPath string) (err error) {
 	return
 }
 
-func (p *Paths) initDataDirectory(dataDirectoryPath, appName string) (err error) {
+// initDataDirectory initializes the data directory path
+func (p *Paths) initDataDirectory(o Options) (err error) {
 	// Path is specified in the options
-	if len(dataDirectoryPath) > 0 {
+	if len(o.DataDirectoryPath) > 0 {
 		// We need the absolute path
-		if p.dataDirectory, err = filepath.Abs(dataDirectoryPath); err != nil {
-			err = fmt.Errorf("computing absolute path of %s failed: %w", dataDirectoryPath, err)
+		if p.dataDirectory, err = filepath.Abs(o.DataDirectoryPath); err != nil {
+			err = fmt.Errorf("computing absolute path of %s failed: %w", o.DataDirectoryPath, err)
 			return
 		}
 		return
@@ -107,7 +157,14 @@ func (p *Paths) initDataDirectory(dataDirectoryPath, appName string) (err error)
 
 	// If the APPDATA env exists, we use it
 	if v := os.Getenv("APPDATA"); len(v) > 0 {
-		p.dataDirectory = filepath.Join(v, appName)
+		p.dataDirectory = filepath.Join(v, o.AppName)
+		return
+	}
+
+	// On Linux, the executable's directory is usually read-only therefore we follow the XDG base directory
+	// specification unless the base directory is specified in the options or contains a bundle
+	// Installs provisioned in a writable executable's directory by previous versions keep using it
+	if p.os == "linux" && len(o.BaseDirectoryPath) == 0 && !p.isBundle() && !p.isWritableLegacyInstall() && p.initXDGDirectories(o.AppName) {
 		return
 	}
 
@@ -116,6 +173,82 @@ func (p *Paths) initDataDirectory(dataDirectoryPath, appName string) (err error)
 	return
 }
 
+// isBundle checks whether the base directory contains a bundle created by Bundle
+func (p *Paths) isBundle() bool {
+	for _, n := range []string{BundleManifestPath, filepath.Join("vendor", "status.json")} {
+		if _, err := os.Stat(filepath.Join(p.baseDirectory, n)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isWritableLegacyInstall checks whether the base directory contains a vendor directory provisioned by a previous
+// version that can still be written to
+func (p *Paths) isWritableLegacyInstall() bool {
+	// Vendor directory has not been provisioned
+	var d = filepath.Join(p.baseDirectory, "vendor")
+	if _, err := os.Stat(filepath.Join(d, "status.json")); err != nil {
+		return false
+	}
+
+	// Vendor directory is read-only
+	f, err := ioutil.TempFile(d, "*.tmp")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// initXDGDirectories initializes the data, cache, config and logs directory paths following the XDG base directory
+// specification. It returns false if the user home directory can't be retrieved.
+func (p *Paths) initXDGDirectories(appName string) bool {
+	// Get directory name
+	var n = appName
+	if n == "" {
+		n = "astilectron"
+	}
+
+	// Loop through directories
+	for _, d := range []struct {
+		env      string
+		fallback []string
+		path     *string
+	}{
+		{env: "XDG_DATA_HOME", fallback: []string{".local", "share"}, path: &p.dataDirectory},
+		{env: "XDG_CACHE_HOME", fallback: []string{".cache"}, path: &p.cacheDirectory},
+		{env: "XDG_CONFIG_HOME", fallback: []string{".config"}, path: &p.configDirectory},
+		{env: "XDG_STATE_HOME", fallback: []string{".local", "state"}, path: &p.logsDirectory},
+	} {
+		v, err := xdgDirectory(d.env, d.fallback...)
+		if err != nil {
+			p.dataDirectory, p.cacheDirectory, p.configDirectory, p.logsDirectory = "", "", "", ""
+			return false
+		}
+		*d.path = filepath.Join(v, n)
+	}
+	p.logsDirectory = filepath.Join(p.logsDirectory, "logs")
+
+	// Vendor directory used to be in the base directory
+	p.legacyVendorDirectory = filepath.Join(p.baseDirectory, "vendor")
+	return true
+}
+
+// xdgDirectory returns the XDG base directory stored in the env variable or, if it is not set or not absolute as
+// required by the specification, its fallback relative to the user home directory
+func xdgDirectory(env string, fallback ...string) (string, error) {
+	if v := os.Getenv(env); filepath.IsAbs(v) {
+		return v, nil
+	}
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting user home directory failed: %w", err)
+	}
+	return filepath.Join(append([]string{h}, fallback...)...), nil
+}
+
 // AstilectronDownloadSrc returns the download URL of the (currently platform-independent) astilectron zip file
 func AstilectronDownloadSrc(versionAstilectron string) string {
 	return fmt.Sprintf("https://github.com/asticode/astilectron/archive/v%s.zip", versionAstilectron)
@@ -157,14 +290,44 @@ func (p *Paths) initAppExecutable(os, appName string) {
 		}
 		p.appExecutable = filepath.Join(p.electronDirectory, appName+".app", "Contents", "MacOS", appName)
 	case "linux":
-		p.appExecutable = filepath.Join(p.electronDirectory, "electron")
+		p.appExecutable = filepath.Join(p.electronDirectory, LinuxExecutableName(appName))
+		if p.appExecutable != filepath.Join(p.electronDirectory, "electron") {
+			p.appExecutableFallback = filepath.Join(p.electronDirectory, "electron")
+		}
 	case "windows":
 		p.appExecutable = filepath.Join(p.electronDirectory, "electron.exe")
 	}
 }
 
+// LinuxExecutableName returns the name the electron binary is renamed to on Linux systems so that the app shows up
+// with its own name in process lists and window managers
+func LinuxExecutableName(appName string) string {
+	if n := strings.Trim(regexpLinuxExecutableName.ReplaceAllString(strings.ToLower(appName), "-"), "-"); n != "" {
+		return n
+	}
+	return "electron"
+}
+
+// resolveAppExecutable resolves the app executable path against what has been provisioned
+// On Linux, the electron binary is renamed after the app by the default provisioner only. When it hasn't been renamed,
+// for instance when provisioning is skipped or done by a custom provisioner, the electron binary is used instead.
+func (p *Paths) resolveAppExecutable() {
+	p.appExecutableResolved = p.appExecutable
+	if p.appExecutableFallback == "" {
+		return
+	}
+	if _, err := os.Stat(p.appExecutable); os.IsNotExist(err) {
+		if _, err = os.Stat(p.appExecutableFallback); err == nil {
+			p.appExecutableResolved = p.appExecutableFallback
+		}
+	}
+}
+
 // AppExecutable returns the app executable path
 func (p Paths) AppExecutable() string {
+	if p.appExecutableResolved != "" {
+		return p.appExecutableResolved
+	}
 	return p.appExecutable
 }
 
@@ -193,11 +356,22 @@ func (p Paths) AstilectronDirectory() string {
 	return p.astilectronDirectory
 }
 
+// AstilectronContentsSHA256 returns the SHA-256 digest the contents of the astilectron archive must match, if pinned by
+// a lockfile
+func (p Paths) AstilectronContentsSHA256() string {
+	return p.astilectronContentsSHA256
+}
+
 // AstilectronDownloadDst returns the astilectron download destination path
 func (p Paths) AstilectronDownloadDst() string {
 	return p.astilectronDownloadDst
 }
 
+// AstilectronDownloadSHA256 returns the SHA-256 digest the astilectron archive must match, if pinned by a lockfile
+func (p Paths) AstilectronDownloadSHA256() string {
+	return p.astilectronDownloadSHA256
+}
+
 // AstilectronDownloadSrc returns the astilectron download source path
 func (p Paths) AstilectronDownloadSrc() string {
 	return p.astilectronDownloadSrc
@@ -208,6 +382,16 @@ func (p Paths) AstilectronUnzipSrc() string {
 	return p.astilectronUnzipSrc
 }
 
+// CacheDirectory returns the directory path archives are downloaded to
+func (p Paths) CacheDirectory() string {
+	return p.cacheDirectory
+}
+
+// ConfigDirectory returns the config directory path
+func (p Paths) ConfigDirectory() string {
+	return p.configDirectory
+}
+
 // DataDirectory returns the data directory path
 func (p Paths) DataDirectory() string {
 	return p.dataDirectory
@@ -223,6 +407,11 @@ func (p Paths) ElectronDownloadDst() string {
 	return p.electronDownloadDst
 }
 
+// ElectronDownloadSHA256 returns the SHA-256 digest the electron archive must match, if pinned by a lockfile
+func (p Paths) ElectronDownloadSHA256() string {
+	return p.electronDownloadSHA256
+}
+
 // ElectronDownloadSrc returns the electron download source path
 func (p Paths) ElectronDownloadSrc() string {
 	return p.electronDownloadSrc
@@ -233,6 +422,16 @@ func (p Paths) ElectronUnzipSrc() string {
 	return p.electronUnzipSrc
 }
 
+// LogsDirectory returns the logs directory path
+func (p Paths) LogsDirectory() string {
+	return p.logsDirectory
+}
+
+// ProvisionLock returns the provision lock path
+func (p Paths) ProvisionLock() string {
+	return p.provisionLock
+}
+
 // ProvisionStatus returns the provision status path
 func (p Paths) ProvisionStatus() string {
 	return p.provisionStatus
@@ -242,3 +441,13 @@ func (p Paths) ProvisionStatus() string {
 func (p Paths) VendorDirectory() string {
 	return p.vendorDirectory
 }
+
+// VersionAstilectron returns the astilectron version
+func (p Paths) VersionAstilectron() string {
+	return p.versionAstilectron
+}
+
+// VersionElectron returns the electron version
+func (p Paths) VersionElectron() string {
+	return p.versionElectron
+}
@@ -1,6 +1,7 @@
 package astilectron
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -17,24 +18,45 @@ func TestPaths(t *testing.T) {
 	ep = filepath.Dir(ep)
 	assert.NoError(t, err)
 
+	for _, v := range []string{"XDG_CACHE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME"} {
+		defer os.Setenv(v, os.Getenv(v))
+		os.Setenv(v, "/xdg/"+v)
+	}
+
 	o := Options{VersionAstilectron: DefaultVersionAstilectron, VersionElectron: DefaultVersionElectron}
 	p, err := newPaths("linux", "amd64", o)
 	assert.NoError(t, err)
-	assert.Equal(t, ep+"/vendor/electron-linux-amd64/electron", p.AppExecutable())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/electron-linux-amd64/electron", p.AppExecutable())
 	assert.Equal(t, "", p.AppIconDarwinSrc())
 	assert.Equal(t, ep, p.BaseDirectory())
-	assert.Equal(t, ep, p.DataDirectory())
-	assert.Equal(t, ep+"/vendor/astilectron/main.js", p.AstilectronApplication())
-	assert.Equal(t, ep+"/vendor/astilectron", p.AstilectronDirectory())
-	assert.Equal(t, ep+"/vendor/astilectron-v"+o.VersionAstilectron+".zip", p.AstilectronDownloadDst())
+	assert.Equal(t, "/xdg/XDG_CACHE_HOME/astilectron", p.CacheDirectory())
+	assert.Equal(t, "/xdg/XDG_CONFIG_HOME/astilectron", p.ConfigDirectory())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron", p.DataDirectory())
+	assert.Equal(t, "/xdg/XDG_STATE_HOME/astilectron/logs", p.LogsDirectory())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/astilectron/main.js", p.AstilectronApplication())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/astilectron", p.AstilectronDirectory())
+	assert.Equal(t, "/xdg/XDG_CACHE_HOME/astilectron/astilectron-v"+o.VersionAstilectron+".zip", p.AstilectronDownloadDst())
 	assert.Equal(t, "https://github.com/asticode/astilectron/archive/v"+o.VersionAstilectron+".zip", p.AstilectronDownloadSrc())
-	assert.Equal(t, ep+"/vendor/astilectron-v"+o.VersionAstilectron+".zip/astilectron-"+o.VersionAstilectron, p.AstilectronUnzipSrc())
-	assert.Equal(t, ep+"/vendor/electron-linux-amd64", p.ElectronDirectory())
-	assert.Equal(t, ep+"/vendor/electron-linux-amd64-v"+o.VersionElectron+".zip", p.ElectronDownloadDst())
+	assert.Equal(t, "/xdg/XDG_CACHE_HOME/astilectron/astilectron-v"+o.VersionAstilectron+".zip/astilectron-"+o.VersionAstilectron, p.AstilectronUnzipSrc())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/electron-linux-amd64", p.ElectronDirectory())
+	assert.Equal(t, "/xdg/XDG_CACHE_HOME/astilectron/electron-linux-amd64-v"+o.VersionElectron+".zip", p.ElectronDownloadDst())
 	assert.Equal(t, "https://github.com/electron/electron/releases/download/v"+o.VersionElectron+"/electron-v"+o.VersionElectron+"-linux-x64.zip", p.ElectronDownloadSrc())
-	assert.Equal(t, ep+"/vendor/electron-linux-amd64-v"+o.VersionElectron+".zip", p.ElectronUnzipSrc())
-	assert.Equal(t, ep+"/vendor/status.json", p.ProvisionStatus())
-	assert.Equal(t, ep+"/vendor", p.VendorDirectory())
+	assert.Equal(t, "/xdg/XDG_CACHE_HOME/astilectron/electron-linux-amd64-v"+o.VersionElectron+".zip", p.ElectronUnzipSrc())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/provision.lock", p.ProvisionLock())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor/status.json", p.ProvisionStatus())
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/astilectron/vendor", p.VendorDirectory())
+	assert.Equal(t, ep+"/vendor", p.legacyVendorDirectory)
+	p, err = newPaths("linux", "amd64", Options{AppIconDefaultPath: "icon.png", AppName: "Test app", VersionAstilectron: DefaultVersionAstilectron, VersionElectron: DefaultVersionElectron})
+	assert.NoError(t, err)
+	assert.Equal(t, "/xdg/XDG_DATA_HOME/Test app/vendor/electron-linux-amd64/test-app", p.AppExecutable())
+	assert.Equal(t, ep+"/icon.png", p.AppIconDefaultSrc())
+	p, err = newPaths("linux", "amd64", Options{AppName: "Test app", BaseDirectoryPath: "/path/to/base/directory", VersionAstilectron: DefaultVersionAstilectron, VersionElectron: DefaultVersionElectron})
+	assert.NoError(t, err)
+	assert.Equal(t, "/path/to/base/directory/vendor/electron-linux-amd64/test-app", p.AppExecutable())
+	assert.Equal(t, "/path/to/base/directory/vendor", p.CacheDirectory())
+	assert.Equal(t, "/path/to/base/directory", p.ConfigDirectory())
+	assert.Equal(t, "/path/to/base/directory/logs", p.LogsDirectory())
+	assert.Equal(t, "", p.legacyVendorDirectory)
 	p, err = newPaths("linux", "", o)
 	assert.NoError(t, err)
 	assert.Equal(t, "https://github.com/electron/electron/releases/download/v"+o.VersionElectron+"/electron-v"+o.VersionElectron+"-linux-ia32.zip", p.ElectronDownloadSrc())
@@ -77,4 +99,57 @@ func TestPaths(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "https://github.com/electron/electron/releases/download/v"+o.VersionElectron+"/electron-v"+o.VersionElectron+"-win32-arm64.zip", p.ElectronDownloadSrc())
 	os.Setenv(k, ad)
+
+	// Custom electron
+	o.CustomElectronPath = "/path/to/electron"
+	p, err = newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	assert.Equal(t, "/path/to/electron", p.AppExecutable())
+}
+
+func TestPaths_AppExecutableFallback(t *testing.T) {
+	// Init
+	var o = Options{AppName: "Test app", BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(p.ElectronDirectory(), 0755))
+
+	// Test nothing has been provisioned
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "test-app"), p.AppExecutable())
+
+	// Test binary has not been renamed
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(p.ElectronDirectory(), "electron"), []byte{}, 0755))
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "test-app"), p.AppExecutable())
+	p.resolveAppExecutable()
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "electron"), p.AppExecutable())
+
+	// Test binary has been renamed
+	assert.NoError(t, os.Rename(filepath.Join(p.ElectronDirectory(), "electron"), filepath.Join(p.ElectronDirectory(), "test-app")))
+	p.resolveAppExecutable()
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "test-app"), p.AppExecutable())
+}
+
+func TestPaths_IsWritableLegacyInstall(t *testing.T) {
+	// Init
+	var p = &Paths{baseDirectory: mockedTempPath()}
+	defer os.RemoveAll(p.baseDirectory)
+
+	// Test vendor directory has not been provisioned
+	assert.False(t, p.isWritableLegacyInstall())
+
+	// Test vendor directory has been provisioned
+	assert.NoError(t, os.MkdirAll(filepath.Join(p.baseDirectory, "vendor"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(p.baseDirectory, "vendor", "status.json"), []byte("{}"), 0644))
+	assert.True(t, p.isWritableLegacyInstall())
+	fis, err := ioutil.ReadDir(filepath.Join(p.baseDirectory, "vendor"))
+	assert.NoError(t, err)
+	assert.Len(t, fis, 1)
+}
+
+func TestLinuxExecutableName(t *testing.T) {
+	assert.Equal(t, "electron", LinuxExecutableName(""))
+	assert.Equal(t, "electron", LinuxExecutableName("  "))
+	assert.Equal(t, "test-app", LinuxExecutableName("Test app"))
+	assert.Equal(t, "my-app-_1.0", LinuxExecutableName(" My App/_1.0!"))
 }
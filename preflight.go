@@ -0,0 +1,316 @@
+package astilectron
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Preflight checks
+const (
+	PreflightCheckDisplay    = "display"
+	PreflightCheckExecutable = "executable"
+	PreflightCheckLibraries  = "libraries"
+	PreflightCheckSandbox    = "sandbox"
+)
+
+// Preflight severities
+const (
+	PreflightSeverityError   = "error"
+	PreflightSeverityWarning = "warning"
+)
+
+// Preflight display servers
+const (
+	PreflightDisplayServerWayland = "wayland"
+	PreflightDisplayServerX11     = "x11"
+)
+
+// Var
+var (
+	preflightLdd               = "ldd"
+	preflightUsernsPaths       = []string{"/proc/sys/kernel/unprivileged_userns_clone", "/proc/sys/kernel/apparmor_restrict_unprivileged_userns"}
+	regexpPreflightMissingLibs = regexp.MustCompile(`^\s*(\S+)\s+=>\s+not found`)
+)
+
+// preflightLibraryPackages indexes the packages providing the libraries electron most often misses by library prefix
+var preflightLibraryPackages = map[string]string{
+	"libasound":     "libasound2 (Debian/Ubuntu) or alsa-lib (Fedora/Arch)",
+	"libatk-1.0":    "libatk1.0-0 (Debian/Ubuntu) or atk (Fedora/Arch)",
+	"libatk-bridge": "libatk-bridge2.0-0 (Debian/Ubuntu) or at-spi2-atk (Fedora/Arch)",
+	"libcups":       "libcups2 (Debian/Ubuntu) or cups-libs (Fedora) or libcups (Arch)",
+	"libdrm":        "libdrm2 (Debian/Ubuntu) or libdrm (Fedora/Arch)",
+	"libgbm":        "libgbm1 (Debian/Ubuntu) or mesa-libgbm (Fedora) or mesa (Arch)",
+	"libgtk-3":      "libgtk-3-0 (Debian/Ubuntu) or gtk3 (Fedora/Arch)",
+	"libnss3":       "libnss3 (Debian/Ubuntu) or nss (Fedora/Arch)",
+	"libnssutil3":   "libnss3 (Debian/Ubuntu) or nss (Fedora/Arch)",
+	"libxkbcommon":  "libxkbcommon0 (Debian/Ubuntu) or libxkbcommon (Fedora/Arch)",
+	"libXss":        "libxss1 (Debian/Ubuntu) or libXScrnSaver (Fedora/Arch)",
+	"libXtst":       "libxtst6 (Debian/Ubuntu) or libXtst (Fedora/Arch)",
+}
+
+// PreflightReport represents the result of the checks run before executing electron
+type PreflightReport struct {
+	DisplayServer string           `json:"displayServer,omitempty"`
+	Issues        []PreflightIssue `json:"issues,omitempty"`
+	// Switches electron should be executed with in this environment
+	// Its --enable-features switch includes the features enabled by the provided switches and replaces theirs
+	Switches []string `json:"switches,omitempty"`
+}
+
+// PreflightIssue represents an issue found by a preflight check
+type PreflightIssue struct {
+	Check       string `json:"check"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+	Severity    string `json:"severity"`
+}
+
+// String implements the fmt.Stringer interface
+func (i PreflightIssue) String() string {
+	if i.Remediation == "" {
+		return fmt.Sprintf("%s: %s", i.Check, i.Message)
+	}
+	return fmt.Sprintf("%s: %s. %s", i.Check, i.Message, i.Remediation)
+}
+
+// PreflightError is returned when preflight checks have found issues that prevent electron from starting
+type PreflightError struct {
+	Issues []PreflightIssue
+}
+
+// Error implements the error interface
+func (e PreflightError) Error() string {
+	var ss []string
+	for _, i := range e.Issues {
+		ss = append(ss, i.String())
+	}
+	return "astilectron: electron can't start: " + strings.Join(ss, ", ")
+}
+
+// Err returns a PreflightError if the report contains errors
+func (r PreflightReport) Err() error {
+	var e PreflightError
+	for _, i := range r.Issues {
+		if i.Severity == PreflightSeverityError {
+			e.Issues = append(e.Issues, i)
+		}
+	}
+	if len(e.Issues) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (r *PreflightReport) add(severity, check, message, remediation string) {
+	r.Issues = append(r.Issues, PreflightIssue{
+		Check:       check,
+		Message:     message,
+		Remediation: remediation,
+		Severity:    severity,
+	})
+}
+
+// Preflight checks whether the provisioned electron can start in the current environment
+// On Linux, it detects the display server, looks for missing shared libraries and checks sandbox permissions.
+// Checks are only run on Linux for now.
+func Preflight(ctx context.Context, p Paths, electronSwitches []string) (r PreflightReport) {
+	// Only Linux is supported for now
+	if runtime.GOOS != "linux" || p.os != "linux" {
+		return
+	}
+
+	// Check executable
+	fi, err := os.Stat(p.AppExecutable())
+	if err != nil {
+		r.add(PreflightSeverityError, PreflightCheckExecutable, fmt.Sprintf("stating %s failed: %s", p.AppExecutable(), err), "Provision electron or fix CustomElectronPath")
+		return
+	} else if fi.Mode()&0111 == 0 {
+		r.add(PreflightSeverityError, PreflightCheckExecutable, fmt.Sprintf("%s is not executable", p.AppExecutable()), fmt.Sprintf("Run chmod +x %s", p.AppExecutable()))
+		return
+	}
+
+	// Run checks
+	preflightDisplay(&r, p.VersionElectron(), electronSwitches)
+	preflightLibraries(ctx, &r, p.AppExecutable())
+	preflightSandbox(&r, p.AppExecutable(), electronSwitches)
+	return
+}
+
+// preflightDisplay detects the display server and chooses the switches electron should use with it
+func preflightDisplay(r *PreflightReport, versionElectron string, electronSwitches []string) {
+	// X11 is preferred whenever it's available, including through XWayland, since it's what electron uses by default
+	if os.Getenv("DISPLAY") != "" {
+		r.DisplayServer = PreflightDisplayServerX11
+		return
+	}
+
+	// Get wayland socket
+	var wayland string
+	if v := os.Getenv("WAYLAND_DISPLAY"); v != "" {
+		if wayland = v; !filepath.IsAbs(wayland) {
+			wayland = filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), v)
+		}
+		if _, err := os.Stat(wayland); err != nil {
+			r.add(PreflightSeverityWarning, PreflightCheckDisplay, fmt.Sprintf("WAYLAND_DISPLAY is set but %s doesn't exist", wayland), "Unset WAYLAND_DISPLAY or make sure the compositor is running")
+			wayland = ""
+		}
+	}
+
+	// Wayland is only supported natively by electron >= 12
+	if wayland != "" {
+		if major, err := strconv.Atoi(strings.SplitN(versionElectron, ".", 2)[0]); err == nil && major >= 12 {
+			r.DisplayServer = PreflightDisplayServerWayland
+			if !hasSwitchPrefix(electronSwitches, "--ozone-platform") {
+				r.Switches = append(r.Switches, mergeSwitches([]string{"--enable-features=UseOzonePlatform,WaylandWindowDecorations"}, electronSwitches)[0], "--ozone-platform=wayland")
+			}
+			return
+		}
+		r.add(PreflightSeverityError, PreflightCheckDisplay, fmt.Sprintf("electron %s doesn't support wayland natively and DISPLAY is not set", versionElectron), "Use electron >= 12 or run XWayland and set DISPLAY")
+		return
+	}
+
+	// No display
+	r.add(PreflightSeverityError, PreflightCheckDisplay, "neither DISPLAY nor WAYLAND_DISPLAY is set", "Run the app from a graphical session or, in CI, under xvfb-run")
+}
+
+// preflightLibraries looks for the shared libraries electron depends on that are missing
+func preflightLibraries(ctx context.Context, r *PreflightReport, executable string) {
+	// Look for ldd
+	ldd, err := exec.LookPath(preflightLdd)
+	if err != nil {
+		r.add(PreflightSeverityWarning, PreflightCheckLibraries, "ldd not found, shared libraries can't be checked", "Install ldd (usually provided by libc-bin or glibc)")
+		return
+	}
+
+	// Execute ldd
+	// ldd exits with a non-zero code when some libraries are missing therefore we only rely on its output
+	var b []byte
+	if b, err = exec.CommandContext(ctx, ldd, executable).Output(); err != nil && len(b) == 0 {
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) {
+			r.add(PreflightSeverityWarning, PreflightCheckLibraries, fmt.Sprintf("executing ldd %s failed: %s", executable, err), "")
+		}
+		return
+	}
+
+	// Parse output
+	var ls []string
+	var ps = make(map[string]bool)
+	var pss []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		m := regexpPreflightMissingLibs.FindStringSubmatch(s.Text())
+		if len(m) < 2 {
+			continue
+		}
+		ls = append(ls, m[1])
+		for prefix, p := range preflightLibraryPackages {
+			if strings.HasPrefix(m[1], prefix+".") && !ps[p] {
+				ps[p] = true
+				pss = append(pss, p)
+			}
+		}
+	}
+
+	// No missing library
+	if len(ls) == 0 {
+		return
+	}
+
+	// Add issue
+	var remediation = "Install the packages providing them"
+	if len(pss) > 0 {
+		remediation = "Install " + strings.Join(pss, ", ")
+	}
+	r.add(PreflightSeverityError, PreflightCheckLibraries, "missing shared libraries "+strings.Join(ls, ", "), remediation)
+}
+
+// preflightSandbox checks whether chromium's sandbox can be used
+// The SUID sandbox helper is only needed when unprivileged user namespaces are disabled
+func preflightSandbox(r *PreflightReport, executable string, electronSwitches []string) {
+	// Sandbox is disabled
+	if hasSwitchPrefix(electronSwitches, "--no-sandbox") {
+		return
+	}
+
+	// Check whether unprivileged user namespaces are available
+	var userns = true
+	for _, p := range preflightUsernsPaths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		v := strings.TrimSpace(string(b))
+		if (strings.HasSuffix(p, "unprivileged_userns_clone") && v == "0") || (strings.HasSuffix(p, "apparmor_restrict_unprivileged_userns") && v == "1") {
+			userns = false
+		}
+	}
+	if userns {
+		return
+	}
+
+	// Check the SUID sandbox helper
+	var path = filepath.Join(filepath.Dir(executable), "chrome-sandbox")
+	var remediation = fmt.Sprintf("Run sudo chown root:root %s && sudo chmod 4755 %s, or enable unprivileged user namespaces", path, path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		r.add(PreflightSeverityError, PreflightCheckSandbox, fmt.Sprintf("unprivileged user namespaces are disabled and %s doesn't exist", path), "Enable unprivileged user namespaces or add --no-sandbox to ElectronSwitches")
+		return
+	}
+	if uid, ok := fileOwnerUID(fi); !ok || uid != 0 || fi.Mode()&os.ModeSetuid == 0 || fi.Mode().Perm() != 0755 {
+		r.add(PreflightSeverityError, PreflightCheckSandbox, fmt.Sprintf("unprivileged user namespaces are disabled and %s is not owned by root with mode 4755", path), remediation)
+	}
+}
+
+// mergeSwitches appends switches b to switches a, merging their --enable-features switches into the first one since
+// electron only takes the last one into account
+func mergeSwitches(a, b []string) (ss []string) {
+	const prefix = "--enable-features="
+	var i = -1
+	var fs []string
+	var m = make(map[string]bool)
+	for _, s := range append(append([]string{}, a...), b...) {
+		// Not a feature switch
+		if !strings.HasPrefix(s, prefix) {
+			ss = append(ss, s)
+			continue
+		}
+
+		// Add features
+		for _, f := range strings.Split(strings.TrimPrefix(s, prefix), ",") {
+			if f != "" && !m[f] {
+				fs = append(fs, f)
+				m[f] = true
+			}
+		}
+		if i < 0 {
+			i = len(ss)
+			ss = append(ss, "")
+		}
+	}
+	if i >= 0 {
+		ss[i] = prefix + strings.Join(fs, ",")
+	}
+	return
+}
+
+// hasSwitchPrefix checks whether a switch starting with the prefix is present
+func hasSwitchPrefix(switches []string, prefix string) bool {
+	for _, s := range switches {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package astilectron
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwnerUID returns the uid of the owner of a file
+func fileOwnerUID(fi os.FileInfo) (int, bool) {
+	s, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(s.Uid), true
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package astilectron
+
+import "os"
+
+// fileOwnerUID returns the uid of the owner of a file
+func fileOwnerUID(fi os.FileInfo) (int, bool) {
+	return 0, false
+}
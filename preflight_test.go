@@ -0,0 +1,97 @@
+package astilectron
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	// Init
+	if runtime.GOOS != "linux" {
+		t.Skip("preflight checks only run on linux")
+	}
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	p, err := newPaths("linux", "amd64", Options{BaseDirectoryPath: d, VersionElectron: "11.4.3"})
+	assert.NoError(t, err)
+	for _, k := range []string{"DISPLAY", "WAYLAND_DISPLAY", "XDG_RUNTIME_DIR"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	defer func(ldd string, userns []string) {
+		preflightLdd = ldd
+		preflightUsernsPaths = userns
+	}(preflightLdd, preflightUsernsPaths)
+	preflightLdd = filepath.Join(d, "ldd")
+	preflightUsernsPaths = []string{filepath.Join(d, "unprivileged_userns_clone")}
+
+	// Missing executable
+	r := Preflight(context.Background(), *p, nil)
+	if assert.Len(t, r.Issues, 1) {
+		assert.Equal(t, PreflightCheckExecutable, r.Issues[0].Check)
+	}
+	assert.Error(t, r.Err())
+
+	// No display, missing libraries and sandbox
+	assert.NoError(t, os.MkdirAll(p.ElectronDirectory(), 0755))
+	assert.NoError(t, ioutil.WriteFile(p.AppExecutable(), []byte("electron"), 0755))
+	assert.NoError(t, ioutil.WriteFile(preflightLdd, []byte("#!/bin/sh\necho '\tlinux-vdso.so.1 (0x00007ffd)'\necho '\tlibnss3.so => not found'\necho '\tlibgbm.so.1 => not found'\nexit 1\n"), 0755))
+	assert.NoError(t, ioutil.WriteFile(preflightUsernsPaths[0], []byte("0\n"), 0644))
+	r = Preflight(context.Background(), *p, nil)
+	var cs []string
+	for _, i := range r.Issues {
+		assert.Equal(t, PreflightSeverityError, i.Severity)
+		cs = append(cs, i.Check)
+	}
+	assert.Equal(t, []string{PreflightCheckDisplay, PreflightCheckLibraries, PreflightCheckSandbox}, cs)
+	assert.Equal(t, "missing shared libraries libnss3.so, libgbm.so.1", r.Issues[1].Message)
+	assert.Equal(t, "Install libnss3 (Debian/Ubuntu) or nss (Fedora/Arch), libgbm1 (Debian/Ubuntu) or mesa-libgbm (Fedora) or mesa (Arch)", r.Issues[1].Remediation)
+	var e PreflightError
+	if assert.True(t, errors.As(r.Err(), &e)) {
+		assert.Len(t, e.Issues, 3)
+	}
+
+	// X11 without sandbox
+	os.Setenv("DISPLAY", ":0")
+	assert.NoError(t, ioutil.WriteFile(preflightLdd, []byte("#!/bin/sh\necho '\tlibnss3.so => /usr/lib/libnss3.so (0x00007f)'\n"), 0755))
+	r = Preflight(context.Background(), *p, []string{"--no-sandbox"})
+	assert.Equal(t, PreflightReport{DisplayServer: PreflightDisplayServerX11}, r)
+	assert.NoError(t, r.Err())
+
+	// Wayland
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(d, "wayland-0"), []byte{}, 0644))
+	os.Setenv("XDG_RUNTIME_DIR", d)
+	os.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	r = Preflight(context.Background(), *p, []string{"--no-sandbox"})
+	assert.Equal(t, PreflightReport{DisplayServer: PreflightDisplayServerX11}, r)
+	os.Unsetenv("DISPLAY")
+	r = Preflight(context.Background(), *p, []string{"--no-sandbox"})
+	if assert.Len(t, r.Issues, 1) {
+		assert.Equal(t, PreflightSeverityError, r.Issues[0].Severity)
+	}
+	p.versionElectron = "13.0.0"
+	r = Preflight(context.Background(), *p, []string{"--no-sandbox"})
+	assert.Equal(t, PreflightReport{DisplayServer: PreflightDisplayServerWayland, Switches: []string{"--enable-features=UseOzonePlatform,WaylandWindowDecorations", "--ozone-platform=wayland"}}, r)
+	r = Preflight(context.Background(), *p, []string{"--no-sandbox", "--enable-features=Test,UseOzonePlatform"})
+	assert.Equal(t, PreflightReport{DisplayServer: PreflightDisplayServerWayland, Switches: []string{"--enable-features=UseOzonePlatform,WaylandWindowDecorations,Test", "--ozone-platform=wayland"}}, r)
+
+	// Headless mode disables the sandbox
+	a, err := New(nil, Options{BaseDirectoryPath: d, Headless: &HeadlessOptions{}, VersionElectron: "11.4.3"})
+	assert.NoError(t, err)
+	defer a.Close()
+	for _, i := range a.Preflight().Issues {
+		assert.NotEqual(t, PreflightCheckSandbox, i.Check)
+	}
+}
+
+func TestMergeSwitches(t *testing.T) {
+	assert.Equal(t, []string{"--a", "--b"}, mergeSwitches([]string{"--a"}, []string{"--b"}))
+	assert.Equal(t, []string{"--a", "--enable-features=A,B,C", "--b"}, mergeSwitches([]string{"--a", "--enable-features=A,B"}, []string{"--b", "--enable-features=B,C"}))
+}
@@ -1,20 +1,32 @@
 package astilectron
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/asticode/go-astikit"
 )
 
 // Var
 var (
-	regexpDarwinInfoPList = regexp.MustCompile("<string>Electron")
+	regexpDarwinInfoPList  = regexp.MustCompile("<string>Electron")
+	regexpProvisionArchive = regexp.MustCompile(`^(astilectron|electron-.+)-v.+\.zip$`)
 )
 
 // Provisioner represents an object capable of provisioning Astilectron
@@ -22,28 +34,97 @@ type Provisioner interface {
 	Provision(ctx context.Context, appName, os, arch, versionAstilectron, versionElectron string, p Paths) error
 }
 
+// Provision modes
+const (
+	// Packages are downloaded if needed
+	ProvisionModeDefault = ""
+	// Packages are never downloaded. Provisioning fails if an archive that needs to be downloaded is missing.
+	ProvisionModeOffline = "offline"
+	// Nothing is written to disk. Provisioning only reports what it would do.
+	ProvisionModeDryRun = "dry-run"
+)
+
+// ProvisionerOptions represents the default provisioner options
+type ProvisionerOptions struct {
+	// Maximum duration to wait for another process to release the vendor directory lock
+	LockTimeout time.Duration
+	// One of the ProvisionMode* constants
+	Mode string
+	// Linux only. If set, a desktop entry is written and the app default icon is installed in the XDG data directory
+	LinuxDesktopEntry *LinuxDesktopEntry
+	// Executed with timing metrics once provisioning has succeeded
+	OnMetrics func(m ProvisionMetrics)
+	// Executed with the provision plan, before anything is provisioned
+	OnPlan func(p ProvisionPlan)
+	// If true, hashes of provisioned files are checked as well when verifying an existing install, which is slower
+	// By default only their presence and size are checked
+	VerifyHashes bool
+}
+
+// LinuxDesktopEntry represents a Linux desktop entry
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/
+type LinuxDesktopEntry struct {
+	Categories []string
+	Comment    string
+	Exec       string // Defaults to the current executable
+}
+
 // mover is a function that moves a package
 type mover func(ctx context.Context, p Paths) (func() error, error)
 
+// unzipper is a function that unzips a package into a directory
+type unzipper func(ctx context.Context, p Paths, dst string, skip func(path string) bool) error
+
 // defaultProvisioner represents the default provisioner
 type defaultProvisioner struct {
-	l                astikit.SeverityLogger
-	moverAstilectron mover
-	moverElectron    mover
+	download            bool // Whether archives are downloaded
+	l                   astikit.SeverityLogger
+	moverAstilectron    mover
+	moverElectron       mover
+	o                   ProvisionerOptions
+	sourceAstilectron   func(p Paths) string
+	sourceElectron      func(p Paths) string
+	unzipperAstilectron unzipper
+	unzipperElectron    unzipper
+}
+
+// NewDefaultProvisioner creates the provisioner used by Astilectron when none is provided, which downloads packages
+// from their official sources
+func NewDefaultProvisioner(l astikit.StdLogger, o ProvisionerOptions) Provisioner {
+	return newDefaultProvisioner(l, o)
 }
 
-func newDefaultProvisioner(l astikit.StdLogger) (dp *defaultProvisioner) {
+func newDefaultProvisioner(l astikit.StdLogger, o ProvisionerOptions) (dp *defaultProvisioner) {
 	d := astikit.NewHTTPDownloader(astikit.HTTPDownloaderOptions{
 		Sender: astikit.HTTPSenderOptions{
 			Logger: l,
 		},
 	})
-	dp = &defaultProvisioner{l: astikit.AdaptStdLogger(l)}
+	dp = &defaultProvisioner{
+		download: true,
+		l:        astikit.AdaptStdLogger(l),
+		o:        o,
+	}
+	dp.unzipperAstilectron, dp.unzipperElectron = dp.pathsUnzippers()
+	dp.sourceAstilectron = func(p Paths) string { return p.AstilectronDownloadSrc() }
+	dp.sourceElectron = func(p Paths) string { return p.ElectronDownloadSrc() }
 	dp.moverAstilectron = func(ctx context.Context, p Paths) (closeFunc func() error, err error) {
+		// Archives placed in the vendor directory beforehand, for instance for offline provisioning, are kept
+		var provided = archiveExists(p.AstilectronDownloadDst())
 		if err = Download(ctx, dp.l, d, p.AstilectronDownloadSrc(), p.AstilectronDownloadDst()); err != nil {
 			return nil, fmt.Errorf("downloading %s into %s failed: %w", p.AstilectronDownloadSrc(), p.AstilectronDownloadDst(), err)
 		}
+		if err = verifyArchive(dp.l, p.AstilectronDownloadDst(), p.AstilectronDownloadSHA256(), p.AstilectronContentsSHA256()); err != nil {
+			// Only archives that have just been downloaded are removed
+			if !provided {
+				os.Remove(p.AstilectronDownloadDst())
+			}
+			return nil, fmt.Errorf("verifying %s failed: %w", p.AstilectronDownloadDst(), err)
+		}
 		return func() (err error) {
+			if provided {
+				return nil
+			}
 			dp.l.Debugf("removing %s", p.AstilectronDownloadDst())
 			if err = os.Remove(p.AstilectronDownloadDst()); err != nil {
 				return fmt.Errorf("removing %s failed: %w", p.AstilectronDownloadDst(), err)
@@ -52,10 +133,22 @@ func newDefaultProvisioner(l astikit.StdLogger) (dp *defaultProvisioner) {
 		}, err
 	}
 	dp.moverElectron = func(ctx context.Context, p Paths) (closeFunc func() error, err error) {
+		// Archives placed in the vendor directory beforehand, for instance for offline provisioning, are kept
+		var provided = archiveExists(p.ElectronDownloadDst())
 		if err = Download(ctx, dp.l, d, p.ElectronDownloadSrc(), p.ElectronDownloadDst()); err != nil {
 			return nil, fmt.Errorf("downloading %s into %s failed: %w", p.ElectronDownloadSrc(), p.ElectronDownloadDst(), err)
 		}
+		if err = verifyArchive(dp.l, p.ElectronDownloadDst(), p.ElectronDownloadSHA256(), ""); err != nil {
+			// Only archives that have just been downloaded are removed
+			if !provided {
+				os.Remove(p.ElectronDownloadDst())
+			}
+			return nil, fmt.Errorf("verifying %s failed: %w", p.ElectronDownloadDst(), err)
+		}
 		return func() (err error) {
+			if provided {
+				return nil
+			}
 			dp.l.Debugf("removing %s", p.ElectronDownloadDst())
 			if err = os.Remove(p.ElectronDownloadDst()); err != nil {
 				return fmt.Errorf("removing %s failed: %w", p.ElectronDownloadDst(), err)
@@ -66,35 +159,366 @@ func newDefaultProvisioner(l astikit.StdLogger) (dp *defaultProvisioner) {
 	return
 }
 
+// archiveExists checks whether an archive exists
+func archiveExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// pathsUnzippers returns unzippers unzipping the unzip sources of the paths
+func (p *defaultProvisioner) pathsUnzippers() (astilectron, electron unzipper) {
+	astilectron = func(ctx context.Context, paths Paths, dst string, skip func(path string) bool) error {
+		return unzip(ctx, p.l, paths.AstilectronUnzipSrc(), dst, skip)
+	}
+	electron = func(ctx context.Context, paths Paths, dst string, skip func(path string) bool) error {
+		return unzip(ctx, p.l, paths.ElectronUnzipSrc(), dst, skip)
+	}
+	return
+}
+
 // provisionStatusElectronKey returns the electron's provision status key
 func provisionStatusElectronKey(os, arch string) string {
 	return fmt.Sprintf("%s-%s", os, arch)
 }
 
 // Provision implements the provisioner interface
-// TODO Package app using electron instead of downloading Electron + Astilectron separately
 func (p *defaultProvisioner) Provision(ctx context.Context, appName, os, arch, versionAstilectron, versionElectron string, paths Paths) (err error) {
+	// Dry run
+	if p.o.Mode == ProvisionModeDryRun {
+		return p.dryRun(paths, os, arch, versionAstilectron, versionElectron)
+	}
+
+	// Report metrics
+	var m ProvisionMetrics
+	var start = time.Now()
+	defer func() {
+		if err == nil {
+			m.Total = time.Since(start)
+			p.reportMetrics(m)
+		}
+	}()
+
+	// Make sure no other process provisions the vendor directory at the same time
+	var fl *fileLock
+	if fl, err = lockFile(ctx, p.l, paths.ProvisionLock(), p.o.LockTimeout); err != nil {
+		err = fmt.Errorf("locking vendor directory failed: %w", err)
+		return
+	}
+	defer func() {
+		if err := fl.unlock(); err != nil {
+			p.l.Error(fmt.Errorf("unlocking vendor directory failed: %w", err))
+		}
+	}()
+	m.Lock = time.Since(start)
+
+	// Migrate vendor directory provisioned in the executable's directory by previous versions
+	if err := migrateVendorDirectory(ctx, p.l, paths, p.o.LockTimeout); err != nil {
+		p.l.Error(fmt.Errorf("migrating vendor directory failed, provisioning it all over again: %w", err))
+	}
+
 	// Retrieve provision status
 	var s ProvisionStatus
 	if s, err = p.ProvisionStatus(paths); err != nil {
 		err = fmt.Errorf("retrieving provisioning status failed: %w", err)
 		return
 	}
-	defer p.updateProvisionStatus(paths, &s)
 
-	// Provision astilectron
-	if err = p.provisionAstilectron(ctx, paths, s, versionAstilectron); err != nil {
-		err = fmt.Errorf("provisioning astilectron failed: %w", err)
+	// Plan
+	var pl ProvisionPlan
+	if pl, err = p.plan(paths, s, os, arch, versionAstilectron, versionElectron); err != nil {
+		err = fmt.Errorf("planning failed: %w", err)
 		return
 	}
-	s.Astilectron = &ProvisionStatusPackage{Version: versionAstilectron}
+
+	// Offline
+	if p.o.Mode == ProvisionModeOffline {
+		if e := newProvisionOfflineError(pl); e != nil {
+			err = e
+			return
+		}
+	}
+	defer func() {
+		if errWrite := writeProvisionStatus(paths, &s); errWrite != nil && err == nil {
+			err = fmt.Errorf("writing provision status failed: %w", errWrite)
+		}
+	}()
+
+	// Packages are provisioned concurrently and an error cancels the other package
+	// The first error is recorded before cancelling so that it's not masked by the other package's cancellation
+	var wg sync.WaitGroup
+	var errPackage error
+	var errPackageOnce sync.Once
+	var psAstilectron, psElectron *ProvisionStatusPackage
+	packageCtx, packageCancel := context.WithCancel(ctx)
+	defer packageCancel()
+	fail := func(err error) {
+		errPackageOnce.Do(func() { errPackage = err })
+		packageCancel()
+	}
+
+	// Provision astilectron
+	if pl.Astilectron != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if psAstilectron, m.Astilectron, err = p.provisionAstilectron(packageCtx, paths, versionAstilectron, s.Astilectron); err != nil {
+				fail(fmt.Errorf("provisioning astilectron failed: %w", err))
+			}
+		}()
+	} else {
+		p.l.Debugf("Astilectron has already been provisioned to version %s, moving on...", versionAstilectron)
+	}
 
 	// Provision electron
-	if err = p.provisionElectron(ctx, paths, s, appName, os, arch, versionElectron); err != nil {
-		err = fmt.Errorf("provisioning electron failed: %w", err)
+	var electronKey = provisionStatusElectronKey(os, arch)
+	if pl.Electron != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if psElectron, m.Electron, err = p.provisionElectron(packageCtx, paths, appName, os, versionElectron, s.Electron[electronKey]); err != nil {
+				fail(fmt.Errorf("provisioning electron failed: %w", err))
+			}
+		}()
+	} else if paths.ElectronUnzipSrc() != "" {
+		p.l.Debugf("Electron has already been provisioned to version %s, moving on...", versionElectron)
+	}
+
+	// Wait
+	wg.Wait()
+
+	// Update provision status with packages that have been provisioned, even if the other one failed
+	if psAstilectron != nil {
+		s.Astilectron = psAstilectron
+	}
+	if psElectron != nil {
+		s.Electron[electronKey] = psElectron
+	}
+
+	// Process errors
+	if errPackage != nil {
+		err = errPackage
+		return
+	}
+
+	// Install desktop entry
+	if os == "linux" && p.o.LinuxDesktopEntry != nil {
+		if err = p.installLinuxDesktopEntry(appName, paths); err != nil {
+			err = fmt.Errorf("installing linux desktop entry failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// dryRun reports what provisioning would do without writing anything to disk
+func (p *defaultProvisioner) dryRun(paths Paths, os, arch, versionAstilectron, versionElectron string) (err error) {
+	// Read provision status
+	var s ProvisionStatus
+	if s, err = ReadProvisionStatus(paths); err != nil {
+		// Provisioning would start all over again
+		p.l.Debug(fmt.Errorf("reading provision status failed: %w", err))
+		s = ProvisionStatus{Electron: make(map[string]*ProvisionStatusPackage)}
+		err = nil
+	}
+
+	// Plan
+	if _, err = p.plan(paths, s, os, arch, versionAstilectron, versionElectron); err != nil {
+		err = fmt.Errorf("planning failed: %w", err)
+		return
+	}
+	return
+}
+
+// ProvisionPlan represents what provisioning will do
+// Packages that are already provisioned are nil
+type ProvisionPlan struct {
+	Astilectron *ProvisionPlanPackage
+	Electron    *ProvisionPlanPackage
+}
+
+// Empty returns whether there's nothing to provision
+func (p ProvisionPlan) Empty() bool {
+	return p.Astilectron == nil && p.Electron == nil
+}
+
+// ProvisionPlanPackage represents what provisioning will do for a package
+type ProvisionPlanPackage struct {
+	Archive         string // Path the archive is stored at before being unzipped
+	Directory       string // Directory the package is unzipped into
+	Download        bool   // Whether the archive has to be downloaded
+	Name            string
+	PreviousVersion string
+	Source          string
+	Verification    ProvisionPackageVerification
+	Version         string
+}
+
+// String implements the fmt.Stringer interface
+func (p ProvisionPlanPackage) String() string {
+	// Reason
+	var reason string
+	switch {
+	case p.Verification.NotProvisioned:
+		reason = fmt.Sprintf("%s is not provisioned", p.Directory)
+	case p.PreviousVersion != p.Version:
+		reason = fmt.Sprintf("version %s is provisioned", p.PreviousVersion)
+	default:
+		var items []string
+		if len(p.Verification.Missing) > 0 {
+			items = append(items, fmt.Sprintf("missing files: %s", strings.Join(p.Verification.Missing, ", ")))
+		}
+		if len(p.Verification.Modified) > 0 {
+			items = append(items, fmt.Sprintf("modified files: %s", strings.Join(p.Verification.Modified, ", ")))
+		}
+		reason = strings.Join(items, ", ")
+	}
+
+	// Action
+	var action string
+	if p.Download {
+		action = fmt.Sprintf("download %s into %s and unzip it into %s", p.Source, p.Archive, p.Directory)
+	} else {
+		action = fmt.Sprintf("unzip %s into %s", p.Archive, p.Directory)
+	}
+	return fmt.Sprintf("%s %s (%s): %s", p.Name, p.Version, reason, action)
+}
+
+// ProvisionMetrics represents the time spent in each provisioning phase
+type ProvisionMetrics struct {
+	Astilectron *ProvisionPackageMetrics // Nil if astilectron didn't need to be provisioned
+	Electron    *ProvisionPackageMetrics // Nil if electron didn't need to be provisioned
+	Lock        time.Duration            // Time spent waiting for the vendor directory lock
+	Total       time.Duration
+}
+
+// ProvisionPackageMetrics represents the time spent in each phase of a package provisioning
+type ProvisionPackageMetrics struct {
+	Download   time.Duration // Time spent downloading, or disembedding, the archive
+	Extraction time.Duration
+	Finish     time.Duration // Time spent in os specific steps such as renaming
+	Manifest   time.Duration // Time spent hashing files for the provision status
+	Replace    time.Duration
+	Reused     int // Number of unchanged files of the previous install that didn't need to be extracted again
+	Total      time.Duration
+}
+
+// String implements the fmt.Stringer interface
+func (m ProvisionPackageMetrics) String() string {
+	return fmt.Sprintf("%s (download: %s, extraction: %s, finish: %s, manifest: %s, replace: %s, reused files: %d)", m.Total, m.Download, m.Extraction, m.Finish, m.Manifest, m.Replace, m.Reused)
+}
+
+// reportMetrics logs metrics and executes the metrics callback
+func (p *defaultProvisioner) reportMetrics(m ProvisionMetrics) {
+	// Log
+	if m.Astilectron != nil {
+		p.l.Infof("Provisioning Astilectron took %s", m.Astilectron)
+	}
+	if m.Electron != nil {
+		p.l.Infof("Provisioning Electron took %s", m.Electron)
+	}
+	p.l.Debugf("Provisioning took %s (lock: %s)", m.Total, m.Lock)
+
+	// Callback
+	if p.o.OnMetrics != nil {
+		p.o.OnMetrics(m)
+	}
+}
+
+// ProvisionOfflineError represents an error returned when packages need to be downloaded in offline mode
+type ProvisionOfflineError struct {
+	Packages []ProvisionPlanPackage
+}
+
+// newProvisionOfflineError returns an error if packages of the plan need to be downloaded
+func newProvisionOfflineError(pl ProvisionPlan) *ProvisionOfflineError {
+	var e = &ProvisionOfflineError{}
+	for _, pp := range []*ProvisionPlanPackage{pl.Astilectron, pl.Electron} {
+		if pp != nil && pp.Download {
+			e.Packages = append(e.Packages, *pp)
+		}
+	}
+	if len(e.Packages) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error implements the error interface
+func (e *ProvisionOfflineError) Error() string {
+	var items []string
+	for _, p := range e.Packages {
+		items = append(items, p.String())
+	}
+	return fmt.Sprintf("offline provisioning is impossible: %s", strings.Join(items, "; "))
+}
+
+// plan computes the provision plan and reports it
+func (p *defaultProvisioner) plan(paths Paths, s ProvisionStatus, os, arch, versionAstilectron, versionElectron string) (pl ProvisionPlan, err error) {
+	// Astilectron
+	if pl.Astilectron, err = p.planPackage(paths.AstilectronDirectory(), paths.AstilectronDownloadDst(), p.source(p.sourceAstilectron, paths), "Astilectron", versionAstilectron, s.Astilectron, "main.js"); err != nil {
+		err = fmt.Errorf("planning astilectron failed: %w", err)
 		return
 	}
-	s.Electron[provisionStatusElectronKey(os, arch)] = &ProvisionStatusPackage{Version: versionElectron}
+
+	// Electron
+	if paths.ElectronUnzipSrc() != "" {
+		if pl.Electron, err = p.planPackage(paths.ElectronDirectory(), paths.ElectronDownloadDst(), p.source(p.sourceElectron, paths), "Electron", versionElectron, s.Electron[provisionStatusElectronKey(os, arch)], electronRequiredFiles(paths)...); err != nil {
+			err = fmt.Errorf("planning electron failed: %w", err)
+			return
+		}
+	}
+
+	// Report
+	for _, pp := range []*ProvisionPlanPackage{pl.Astilectron, pl.Electron} {
+		if pp != nil {
+			p.l.Infof("Provisioning will %s", pp)
+		}
+	}
+	if p.o.OnPlan != nil {
+		p.o.OnPlan(pl)
+	}
+	return
+}
+
+// planPackage computes the provision plan of a package. It returns nil if the package is already provisioned.
+func (p *defaultProvisioner) planPackage(directory, archive, source, name, version string, s *ProvisionStatusPackage, required ...string) (pp *ProvisionPlanPackage, err error) {
+	// Init
+	pp = &ProvisionPlanPackage{
+		Archive:   archive,
+		Directory: directory,
+		Name:      name,
+		Source:    source,
+		Version:   version,
+	}
+
+	// Package has already been provisioned
+	if s != nil {
+		// Verify
+		pp.PreviousVersion = s.Version
+		if pp.Verification, err = verifyProvisionStatusPackage(directory, s, p.o.VerifyHashes, required...); err != nil {
+			err = fmt.Errorf("verifying %s failed: %w", name, err)
+			return
+		}
+		sort.Strings(pp.Verification.Missing)
+		sort.Strings(pp.Verification.Modified)
+
+		// Package is valid
+		if s.Version == version && pp.Verification.Valid() {
+			pp = nil
+			return
+		}
+	} else {
+		pp.Verification.NotProvisioned = true
+	}
+
+	// Check whether the archive needs to be downloaded
+	if p.download {
+		if _, errStat := os.Stat(archive); errStat != nil {
+			pp.Download = true
+		}
+	}
 	return
 }
 
@@ -106,7 +530,438 @@ type ProvisionStatus struct {
 
 // ProvisionStatusPackage represents the provision status of a package
 type ProvisionStatusPackage struct {
-	Version string `json:"version"`
+	Files       map[string]ProvisionStatusFile `json:"files,omitempty"` // Indexed by slash separated path relative to the package directory
+	InstalledAt *time.Time                     `json:"installedAt,omitempty"`
+	Source      string                         `json:"source,omitempty"`
+	Version     string                         `json:"version"`
+}
+
+// ProvisionStatusFile represents the provision status of a file
+type ProvisionStatusFile struct {
+	Hash string `json:"hash"` // Hex encoded SHA-256
+	Size int64  `json:"size"`
+}
+
+// ProvisionVerification represents the result of a provision verification
+type ProvisionVerification struct {
+	Astilectron ProvisionPackageVerification
+	Electron    ProvisionPackageVerification
+}
+
+// Valid returns whether all packages are valid
+func (v ProvisionVerification) Valid() bool {
+	return v.Astilectron.Valid() && v.Electron.Valid()
+}
+
+// ProvisionPackageVerification represents the result of a package provision verification
+type ProvisionPackageVerification struct {
+	Missing        []string // Files that are missing
+	Modified       []string // Files whose size or hash doesn't match the provision status
+	NotProvisioned bool     // The package doesn't appear in the provision status or its directory is missing
+}
+
+// Valid returns whether the package is valid
+func (v ProvisionPackageVerification) Valid() bool {
+	return !v.NotProvisioned && len(v.Missing) == 0 && len(v.Modified) == 0
+}
+
+// VerifyProvisioning checks that every file listed in the provision status is present and untouched
+func VerifyProvisioning(paths Paths) (v ProvisionVerification, err error) {
+	// Read provision status
+	var s ProvisionStatus
+	if s, err = ReadProvisionStatus(paths); err != nil {
+		err = fmt.Errorf("reading provision status failed: %w", err)
+		return
+	}
+
+	// Verify astilectron
+	if v.Astilectron, err = verifyProvisionStatusPackage(paths.AstilectronDirectory(), s.Astilectron, true, "main.js"); err != nil {
+		err = fmt.Errorf("verifying astilectron failed: %w", err)
+		return
+	}
+
+	// Verify electron
+	if paths.ElectronDirectory() != "" {
+		if v.Electron, err = verifyProvisionStatusPackage(paths.ElectronDirectory(), s.Electron[provisionStatusElectronKey(paths.os, paths.arch)], true, electronRequiredFiles(paths)...); err != nil {
+			err = fmt.Errorf("verifying electron failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// ReadProvisionStatus reads the provision status
+// A missing status is not an error and returns an empty status
+func ReadProvisionStatus(paths Paths) (s ProvisionStatus, err error) {
+	// Open the file
+	var f *os.File
+	s.Electron = make(map[string]*ProvisionStatusPackage)
+	if f, err = os.Open(paths.ProvisionStatus()); err != nil {
+		if !os.IsNotExist(err) {
+			err = fmt.Errorf("opening file %s failed: %w", paths.ProvisionStatus(), err)
+		} else {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	// Unmarshal
+	if err = json.NewDecoder(f).Decode(&s); err != nil {
+		err = fmt.Errorf("json decoding from %s failed: %w", paths.ProvisionStatus(), err)
+		return
+	}
+	if s.Electron == nil {
+		s.Electron = make(map[string]*ProvisionStatusPackage)
+	}
+	return
+}
+
+// PruneProvisioning removes what previous provisionings left behind in the vendor directory: archives of versions
+// other than the ones in paths or in the provision status, and temporary directories of interrupted provisionings
+// It returns the removed paths
+func PruneProvisioning(ctx context.Context, l astikit.StdLogger, paths Paths, lockTimeout time.Duration) (removed []string, err error) {
+	// Make sure no other process provisions the vendor directory at the same time
+	var sl = astikit.AdaptStdLogger(l)
+	var fl *fileLock
+	if fl, err = lockFile(ctx, sl, paths.ProvisionLock(), lockTimeout); err != nil {
+		err = fmt.Errorf("locking vendor directory failed: %w", err)
+		return
+	}
+	defer func() {
+		if err := fl.unlock(); err != nil {
+			sl.Error(fmt.Errorf("unlocking vendor directory failed: %w", err))
+		}
+	}()
+
+	// Read provision status
+	var s ProvisionStatus
+	if s, err = ReadProvisionStatus(paths); err != nil {
+		err = fmt.Errorf("reading provision status failed: %w", err)
+		return
+	}
+
+	// Archives that are kept
+	var keep = map[string]bool{
+		filepath.Base(paths.AstilectronDownloadDst()): true,
+	}
+	if paths.ElectronDownloadDst() != "" {
+		keep[filepath.Base(paths.ElectronDownloadDst())] = true
+	}
+	if s.Astilectron != nil {
+		keep[fmt.Sprintf("astilectron-v%s.zip", s.Astilectron.Version)] = true
+	}
+	for k, ps := range s.Electron {
+		if ps != nil {
+			keep[fmt.Sprintf("electron-%s-v%s.zip", k, ps.Version)] = true
+		}
+	}
+
+	// Loop through directories
+	var dirs = []string{paths.VendorDirectory()}
+	if paths.CacheDirectory() != paths.VendorDirectory() {
+		dirs = append(dirs, paths.CacheDirectory())
+	}
+	for _, dir := range dirs {
+		// Read directory
+		var fis []os.FileInfo
+		if fis, err = ioutil.ReadDir(dir); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			err = fmt.Errorf("reading directory %s failed: %w", dir, err)
+			return
+		}
+
+		// Loop through files
+		for _, fi := range fis {
+			// Check whether the file should be removed
+			var n = fi.Name()
+			if fi.IsDir() {
+				if !strings.HasSuffix(n, ".tmp") && !strings.HasSuffix(n, ".old") {
+					continue
+				}
+			} else if !regexpProvisionArchive.MatchString(n) || keep[n] {
+				continue
+			}
+
+			// Remove
+			var p = filepath.Join(dir, n)
+			sl.Debugf("Removing %s", p)
+			if err = os.RemoveAll(p); err != nil {
+				err = fmt.Errorf("removing %s failed: %w", p, err)
+				return
+			}
+			removed = append(removed, p)
+		}
+	}
+	return
+}
+
+// migrateVendorDirectory moves the vendor directory provisioned in the executable's directory by previous versions
+// to the XDG data directory, and its archives to the cache directory, so that it doesn't have to be provisioned again
+// Files that can't be moved, for instance because the executable's directory is read-only, are copied instead
+func migrateVendorDirectory(ctx context.Context, l astikit.SeverityLogger, paths Paths, lockTimeout time.Duration) (err error) {
+	// Nothing to migrate
+	if paths.legacyVendorDirectory == "" || paths.legacyVendorDirectory == paths.VendorDirectory() {
+		return
+	}
+
+	// Vendor directory has already been provisioned or migrated
+	if _, err = os.Stat(paths.ProvisionStatus()); err == nil {
+		return
+	} else if !os.IsNotExist(err) {
+		err = fmt.Errorf("stating %s failed: %w", paths.ProvisionStatus(), err)
+		return
+	}
+
+	// Legacy vendor directory has not been provisioned
+	var legacyStatus = filepath.Join(paths.legacyVendorDirectory, filepath.Base(paths.ProvisionStatus()))
+	if _, err = os.Stat(legacyStatus); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("stating %s failed: %w", legacyStatus, err)
+		}
+		return
+	}
+
+	// Make sure no other process provisions the legacy vendor directory while it's migrated
+	// A read-only legacy vendor directory can't be locked, but it can't be provisioned by this user either
+	var fl *fileLock
+	var legacyLock = filepath.Join(paths.legacyVendorDirectory, filepath.Base(paths.ProvisionLock()))
+	if fl, err = lockFile(ctx, l, legacyLock, lockTimeout); err != nil {
+		if !errors.Is(err, os.ErrPermission) {
+			err = fmt.Errorf("locking legacy vendor directory failed: %w", err)
+			return
+		}
+		l.Debugf("Locking %s is not permitted, migrating without it", legacyLock)
+		err = nil
+	}
+	var moved = true
+	defer func() {
+		if fl == nil {
+			return
+		}
+		if err := fl.unlock(); err != nil {
+			l.Error(fmt.Errorf("unlocking legacy vendor directory failed: %w", err))
+			return
+		}
+
+		// Remove legacy vendor directory
+		if moved {
+			os.Remove(paths.legacyVendorDirectory)
+		}
+	}()
+
+	// Read legacy vendor directory
+	l.Infof("Migrating %s to %s", paths.legacyVendorDirectory, paths.VendorDirectory())
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(paths.legacyVendorDirectory); err != nil {
+		err = fmt.Errorf("reading directory %s failed: %w", paths.legacyVendorDirectory, err)
+		return
+	}
+
+	// Loop through files
+	// The provision status is migrated last so that an interrupted migration is started all over again
+	for _, fi := range append(fis, nil) {
+		// Get paths
+		var n string
+		if fi == nil {
+			n = filepath.Base(paths.ProvisionStatus())
+		} else if n = fi.Name(); n == filepath.Base(paths.ProvisionStatus()) || n == filepath.Base(paths.ProvisionLock()) || strings.HasSuffix(n, ".tmp") || strings.HasSuffix(n, ".old") {
+			continue
+		}
+		var src, dst = filepath.Join(paths.legacyVendorDirectory, n), filepath.Join(paths.VendorDirectory(), n)
+		if fi != nil && !fi.IsDir() && regexpProvisionArchive.MatchString(n) {
+			dst = filepath.Join(paths.CacheDirectory(), n)
+		}
+
+		// Make sure the directory exists
+		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(dst), err)
+			return
+		}
+
+		// Move
+		l.Debugf("Moving %s to %s", src, dst)
+		if errRename := os.Rename(src, dst); errRename == nil {
+			continue
+		}
+
+		// Copy
+		moved = false
+		l.Debugf("Moving %s failed, copying it to %s instead", src, dst)
+		if err = astikit.CopyFile(ctx, dst, src, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s to %s failed: %w", src, dst, err)
+			return
+		}
+	}
+	return
+}
+
+// electronRequiredFiles returns the files that must exist in the electron directory, relative to it
+// The default provisioner always renames the electron binary, therefore the fallback executable is not used here
+func electronRequiredFiles(paths Paths) []string {
+	if rel, err := filepath.Rel(paths.ElectronDirectory(), paths.appExecutable); err == nil {
+		return []string{filepath.ToSlash(rel)}
+	}
+	return nil
+}
+
+// verifyProvisionStatusPackage verifies a package directory against its provision status
+// Provision statuses without files, such as the ones written by older versions, only check the directory exists as
+// well as the required files, which are slash separated and relative to the directory
+func verifyProvisionStatusPackage(dir string, s *ProvisionStatusPackage, hash bool, required ...string) (v ProvisionPackageVerification, err error) {
+	// Package has not been provisioned
+	if s == nil {
+		v.NotProvisioned = true
+		return
+	}
+
+	// Directory is missing
+	if _, err = os.Stat(dir); err != nil {
+		if !os.IsNotExist(err) {
+			err = fmt.Errorf("stating %s failed: %w", dir, err)
+			return
+		}
+		err = nil
+		v.NotProvisioned = true
+		return
+	}
+
+	// Loop through files
+	for n, f := range s.Files {
+		// Stat
+		var p = filepath.Join(dir, filepath.FromSlash(n))
+		var fi os.FileInfo
+		if fi, err = os.Stat(p); err != nil {
+			if !os.IsNotExist(err) {
+				err = fmt.Errorf("stating %s failed: %w", p, err)
+				return
+			}
+			err = nil
+			v.Missing = append(v.Missing, n)
+			continue
+		}
+
+		// Check size
+		if fi.Size() != f.Size {
+			v.Modified = append(v.Modified, n)
+			continue
+		}
+
+		// Check hash
+		if hash {
+			var h string
+			if h, err = hashFile(p); err != nil {
+				err = fmt.Errorf("hashing %s failed: %w", p, err)
+				return
+			}
+			if h != f.Hash {
+				v.Modified = append(v.Modified, n)
+			}
+		}
+	}
+
+	// Loop through required files
+	for _, n := range required {
+		// File has already been checked
+		if _, ok := s.Files[n]; ok {
+			continue
+		}
+
+		// Stat
+		var p = filepath.Join(dir, filepath.FromSlash(n))
+		if _, err = os.Stat(p); err != nil {
+			if !os.IsNotExist(err) {
+				err = fmt.Errorf("stating %s failed: %w", p, err)
+				return
+			}
+			err = nil
+			v.Missing = append(v.Missing, n)
+		}
+	}
+	return
+}
+
+// newProvisionStatusPackage creates a new package provision status based on the content of its directory
+func newProvisionStatusPackage(dir, source, version string) (s *ProvisionStatusPackage, err error) {
+	// Init
+	var n = time.Now().UTC()
+	s = &ProvisionStatusPackage{
+		InstalledAt: &n,
+		Source:      source,
+		Version:     version,
+	}
+
+	// List files
+	if s.Files, err = listFiles(dir); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+	return
+}
+
+// listFiles lists regular files of a directory, indexed by their slash separated path relative to the directory
+func listFiles(dir string) (fs map[string]ProvisionStatusFile, err error) {
+	// Walk
+	fs = make(map[string]ProvisionStatusFile)
+	if err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		// Check error
+		if err != nil {
+			return err
+		}
+
+		// Only regular files are listed
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// Get relative path
+		var rel string
+		if rel, err = filepath.Rel(dir, path); err != nil {
+			return fmt.Errorf("getting relative path of %s failed: %w", path, err)
+		}
+
+		// Hash
+		var h string
+		if h, err = hashFile(path); err != nil {
+			return fmt.Errorf("hashing %s failed: %w", path, err)
+		}
+
+		// Add file
+		fs[filepath.ToSlash(rel)] = ProvisionStatusFile{
+			Hash: h,
+			Size: info.Size(),
+		}
+		return nil
+	}); err != nil {
+		err = fmt.Errorf("walking through %s failed: %w", dir, err)
+		return
+	}
+	return
+}
+
+// hashFile returns the hex encoded SHA-256 of a file
+func hashFile(path string) (h string, err error) {
+	// Open file
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", path, err)
+		return
+	}
+	defer f.Close()
+
+	// Hash
+	var hh = sha256.New()
+	if _, err = io.Copy(hh, f); err != nil {
+		err = fmt.Errorf("copying %s failed: %w", path, err)
+		return
+	}
+	h = hex.EncodeToString(hh.Sum(nil))
+	return
 }
 
 // ProvisionStatus returns the provision status
@@ -133,45 +988,68 @@ func (p *defaultProvisioner) ProvisionStatus(paths Paths) (s ProvisionStatus, er
 		if errLocal = os.RemoveAll(f.Name()); errLocal != nil {
 			p.l.Error(fmt.Errorf("removing %s failed: %w", f.Name(), errLocal))
 		}
+		s = ProvisionStatus{Electron: make(map[string]*ProvisionStatusPackage)}
 		return
 	}
+	if s.Electron == nil {
+		s.Electron = make(map[string]*ProvisionStatusPackage)
+	}
 	return
 }
 
-// ProvisionStatus updates the provision status
-func (p *defaultProvisioner) updateProvisionStatus(paths Paths, s *ProvisionStatus) (err error) {
+// writeProvisionStatus writes the provision status
+// The status is written in a temporary file first and then renamed so that it is never partially written
+func writeProvisionStatus(paths Paths, s *ProvisionStatus) (err error) {
 	// Create the file
 	var f *os.File
-	if f, err = os.Create(paths.ProvisionStatus()); err != nil {
-		err = fmt.Errorf("creating file %s failed: %w", paths.ProvisionStatus(), err)
+	var tmp = paths.ProvisionStatus() + ".tmp"
+	if f, err = os.Create(tmp); err != nil {
+		err = fmt.Errorf("creating file %s failed: %w", tmp, err)
 		return
 	}
-	defer f.Close()
 
 	// Marshal
 	if err = json.NewEncoder(f).Encode(s); err != nil {
-		err = fmt.Errorf("json encoding into %s failed: %w", paths.ProvisionStatus(), err)
+		f.Close()
+		err = fmt.Errorf("json encoding into %s failed: %w", tmp, err)
+		return
+	}
+
+	// Close
+	if err = f.Close(); err != nil {
+		err = fmt.Errorf("closing %s failed: %w", tmp, err)
+		return
+	}
+
+	// Rename
+	if err = os.Rename(tmp, paths.ProvisionStatus()); err != nil {
+		err = fmt.Errorf("renaming %s into %s failed: %w", tmp, paths.ProvisionStatus(), err)
 		return
 	}
 	return
 }
 
 // provisionAstilectron provisions astilectron
-func (p *defaultProvisioner) provisionAstilectron(ctx context.Context, paths Paths, s ProvisionStatus, versionAstilectron string) error {
-	return p.provisionPackage(ctx, paths, s.Astilectron, p.moverAstilectron, "Astilectron", versionAstilectron, paths.AstilectronUnzipSrc(), paths.AstilectronDirectory(), nil)
+func (p *defaultProvisioner) provisionAstilectron(ctx context.Context, paths Paths, versionAstilectron string, previous *ProvisionStatusPackage) (*ProvisionStatusPackage, *ProvisionPackageMetrics, error) {
+	return p.provisionPackage(ctx, paths, p.moverAstilectron, p.unzipperAstilectron, "Astilectron", versionAstilectron, p.source(p.sourceAstilectron, paths), paths.AstilectronDirectory(), previous, nil)
 }
 
 // provisionElectron provisions electron
-func (p *defaultProvisioner) provisionElectron(ctx context.Context, paths Paths, s ProvisionStatus, appName, os, arch, versionElectron string) error {
-	if paths.ElectronUnzipSrc() == "" {
-		return nil
+func (p *defaultProvisioner) provisionElectron(ctx context.Context, paths Paths, appName, os, versionElectron string, previous *ProvisionStatusPackage) (*ProvisionStatusPackage, *ProvisionPackageMetrics, error) {
+	// Darwin finishing rewrites files in place, which would alter the previous install through hard links
+	if os == "darwin" {
+		previous = nil
 	}
-	return p.provisionPackage(ctx, paths, s.Electron[provisionStatusElectronKey(os, arch)], p.moverElectron, "Electron", versionElectron, paths.ElectronUnzipSrc(), paths.ElectronDirectory(), func() (err error) {
+	return p.provisionPackage(ctx, paths, p.moverElectron, p.unzipperElectron, "Electron", versionElectron, p.source(p.sourceElectron, paths), paths.ElectronDirectory(), previous, func(dir string) (err error) {
 		switch os {
 		case "darwin":
-			if err = p.provisionElectronFinishDarwin(appName, paths); err != nil {
+			if err = p.provisionElectronFinishDarwin(appName, dir, paths); err != nil {
 				return fmt.Errorf("finishing provisioning electron for darwin systems failed: %w", err)
 			}
+		case "linux":
+			if err = p.provisionElectronFinishLinux(appName, dir); err != nil {
+				return fmt.Errorf("finishing provisioning electron for linux systems failed: %w", err)
+			}
 		default:
 			p.l.Debug("System doesn't require finshing provisioning electron, moving on...")
 		}
@@ -179,26 +1057,36 @@ func (p *defaultProvisioner) provisionElectron(ctx context.Context, paths Paths,
 	})
 }
 
-// provisionPackage provisions a package
-func (p *defaultProvisioner) provisionPackage(ctx context.Context, paths Paths, s *ProvisionStatusPackage, m mover, name, version, pathUnzipSrc, pathDirectory string, finish func() error) (err error) {
-	// Package has already been provisioned
-	if s != nil && s.Version == version {
-		p.l.Debugf("%s has already been provisioned to version %s, moving on...", name, version)
-		return
+// source returns the source of a package
+func (p *defaultProvisioner) source(fn func(p Paths) string, paths Paths) string {
+	if fn == nil {
+		return ""
 	}
-	p.l.Debugf("Provisioning %s...", name)
+	return fn(paths)
+}
 
-	// Remove previous install
-	p.l.Debugf("Removing directory %s", pathDirectory)
-	if err = os.RemoveAll(pathDirectory); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing %s failed: %w", pathDirectory, err)
-	}
+// provisionPackage provisions a package and returns its new provision status
+// The package is extracted and finished in a temporary directory which then atomically replaces the previous install
+// so that a crash mid-provision never leaves a half-populated directory behind
+func (p *defaultProvisioner) provisionPackage(ctx context.Context, paths Paths, m mover, u unzipper, name, version, source, pathDirectory string, previous *ProvisionStatusPackage, finish func(dir string) error) (ps *ProvisionStatusPackage, pm *ProvisionPackageMetrics, err error) {
+	// Log
+	p.l.Debugf("Provisioning %s...", name)
+	pm = &ProvisionPackageMetrics{}
+	var start = time.Now()
+	defer func() {
+		if pm != nil {
+			pm.Total = time.Since(start)
+		}
+	}()
 
 	// Move
 	var closeFunc func() error
-	if closeFunc, err = m(ctx, paths); err != nil {
-		return fmt.Errorf("moving %s failed: %w", name, err)
+	if m != nil {
+		if closeFunc, err = m(ctx, paths); err != nil {
+			return nil, nil, fmt.Errorf("moving %s failed: %w", name, err)
+		}
 	}
+	pm.Download = time.Since(start)
 
 	// Make sure to close
 	defer func() {
@@ -212,35 +1100,173 @@ func (p *defaultProvisioner) provisionPackage(ctx context.Context, paths Paths,
 		}
 	}()
 
+	// Remove leftovers of a previous failed provisioning
+	var pathTmp = pathDirectory + ".tmp"
+	p.l.Debugf("Removing directory %s", pathTmp)
+	if err = os.RemoveAll(pathTmp); err != nil {
+		return nil, nil, fmt.Errorf("removing %s failed: %w", pathTmp, err)
+	}
+
+	// Clean up on error
+	defer func() {
+		if err != nil {
+			os.RemoveAll(pathTmp)
+		}
+	}()
+
 	// Create directory
-	p.l.Debugf("Creating directory %s", pathDirectory)
-	if err = os.MkdirAll(pathDirectory, 0755); err != nil {
-		return fmt.Errorf("mkdirall %s failed: %w", pathDirectory, err)
+	p.l.Debugf("Creating directory %s", pathTmp)
+	if err = os.MkdirAll(pathTmp, 0755); err != nil {
+		return nil, nil, fmt.Errorf("mkdirall %s failed: %w", pathTmp, err)
+	}
+
+	// Reuse unchanged files of the previous install
+	var skip func(path string) bool
+	var t = time.Now()
+	if previous != nil && previous.Version == version && previous.Source == source {
+		if pm.Reused, err = p.seedDirectory(pathDirectory, pathTmp, previous); err != nil {
+			return nil, nil, fmt.Errorf("seeding %s with %s failed: %w", pathTmp, pathDirectory, err)
+		}
+		if pm.Reused > 0 {
+			p.l.Debugf("Reusing %d unchanged file(s) of %s", pm.Reused, pathDirectory)
+			skip = func(path string) bool {
+				_, err := os.Lstat(path)
+				return err == nil
+			}
+		}
 	}
 
 	// Unzip
-	if err = Unzip(ctx, p.l, pathUnzipSrc, pathDirectory); err != nil {
-		return fmt.Errorf("unzipping %s into %s failed: %w", pathUnzipSrc, pathDirectory, err)
+	if err = u(ctx, paths, pathTmp, skip); err != nil {
+		return nil, nil, fmt.Errorf("unzipping %s into %s failed: %w", name, pathTmp, err)
 	}
+	pm.Extraction = time.Since(t)
 
 	// Finish
+	t = time.Now()
 	if finish != nil {
-		if err = finish(); err != nil {
-			return fmt.Errorf("finishing failed: %w", err)
+		if err = finish(pathTmp); err != nil {
+			return nil, nil, fmt.Errorf("finishing failed: %w", err)
 		}
 	}
+	pm.Finish = time.Since(t)
+
+	// Create provision status
+	t = time.Now()
+	if ps, err = newProvisionStatusPackage(pathTmp, source, version); err != nil {
+		return nil, nil, fmt.Errorf("creating provision status failed: %w", err)
+	}
+	pm.Manifest = time.Since(t)
+
+	// Replace previous install
+	t = time.Now()
+	if err = p.replaceDirectory(pathTmp, pathDirectory); err != nil {
+		return nil, nil, fmt.Errorf("replacing %s with %s failed: %w", pathDirectory, pathTmp, err)
+	}
+	pm.Replace = time.Since(t)
+	return
+}
+
+// seedDirectory hard links the files of the previous install that still match its provision status into dst, so that
+// they are not extracted again. Files are hard linked rather than moved so that the previous install stays intact
+// until it's replaced.
+func (p *defaultProvisioner) seedDirectory(src, dst string, previous *ProvisionStatusPackage) (n int, err error) {
+	// Sort files so that seeding is deterministic
+	var names []string
+	for name := range previous.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Loop through files
+	for _, name := range names {
+		// Get paths
+		var f = previous.Files[name]
+		var pathSrc, pathDst = filepath.Join(src, filepath.FromSlash(name)), filepath.Join(dst, filepath.FromSlash(name))
+
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Lstat(pathSrc); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return 0, fmt.Errorf("stating %s failed: %w", pathSrc, err)
+		}
+
+		// Check size
+		if !fi.Mode().IsRegular() || fi.Size() != f.Size {
+			continue
+		}
+
+		// Check hash
+		var h string
+		if h, err = hashFile(pathSrc); err != nil {
+			return 0, fmt.Errorf("hashing %s failed: %w", pathSrc, err)
+		} else if h != f.Hash {
+			continue
+		}
+
+		// Make sure the directory exists
+		if err = os.MkdirAll(filepath.Dir(pathDst), 0755); err != nil {
+			return 0, fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(pathDst), err)
+		}
+
+		// Link
+		if err = os.Link(pathSrc, pathDst); err != nil {
+			// Hard links may not be supported, in which case the file is extracted
+			p.l.Debugf("Linking %s to %s failed: %s", pathSrc, pathDst, err)
+			err = nil
+			continue
+		}
+		n++
+	}
+	return
+}
+
+// replaceDirectory replaces dst with src using renames only
+func (p *defaultProvisioner) replaceDirectory(src, dst string) (err error) {
+	// Remove leftovers of a previous failed replacement
+	var old = dst + ".old"
+	if err = os.RemoveAll(old); err != nil {
+		return fmt.Errorf("removing %s failed: %w", old, err)
+	}
+
+	// Move previous install out of the way
+	if _, err = os.Stat(dst); err == nil {
+		p.l.Debugf("Renaming %s into %s", dst, old)
+		if err = os.Rename(dst, old); err != nil {
+			return fmt.Errorf("renaming %s into %s failed: %w", dst, old, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("stating %s failed: %w", dst, err)
+	}
+
+	// Move new install in place
+	p.l.Debugf("Renaming %s into %s", src, dst)
+	if err = os.Rename(src, dst); err != nil {
+		return fmt.Errorf("renaming %s into %s failed: %w", src, dst, err)
+	}
+
+	// Remove previous install
+	p.l.Debugf("Removing directory %s", old)
+	if err = os.RemoveAll(old); err != nil {
+		// Only log the error
+		p.l.Error(fmt.Errorf("removing %s failed: %w", old, err))
+		err = nil
+	}
 	return
 }
 
 // provisionElectronFinishDarwin finishes provisioning electron for Darwin systems
 // https://github.com/electron/electron/blob/v1.8.1/docs/tutorial/application-distribution.md#macos
-func (p *defaultProvisioner) provisionElectronFinishDarwin(appName string, paths Paths) (err error) {
+func (p *defaultProvisioner) provisionElectronFinishDarwin(appName, dir string, paths Paths) (err error) {
 	// Log
 	p.l.Debug("Finishing provisioning electron for darwin system")
 
 	// Custom app icon
 	if paths.AppIconDarwinSrc() != "" {
-		if err = p.provisionElectronFinishDarwinCopy(paths); err != nil {
+		if err = p.provisionElectronFinishDarwinCopy(dir, paths); err != nil {
 			return fmt.Errorf("copying for darwin system finish failed: %w", err)
 		}
 	}
@@ -248,12 +1274,12 @@ func (p *defaultProvisioner) provisionElectronFinishDarwin(appName string, paths
 	// Custom app name
 	if appName != "" {
 		// Replace
-		if err = p.provisionElectronFinishDarwinReplace(appName, paths); err != nil {
+		if err = p.provisionElectronFinishDarwinReplace(appName, dir); err != nil {
 			return fmt.Errorf("replacing for darwin system finish failed: %w", err)
 		}
 
 		// Rename
-		if err = p.provisionElectronFinishDarwinRename(appName, paths); err != nil {
+		if err = p.provisionElectronFinishDarwinRename(appName, dir); err != nil {
 			return fmt.Errorf("renaming for darwin system finish failed: %w", err)
 		}
 	}
@@ -261,9 +1287,9 @@ func (p *defaultProvisioner) provisionElectronFinishDarwin(appName string, paths
 }
 
 // provisionElectronFinishDarwinCopy copies the proper darwin files
-func (p *defaultProvisioner) provisionElectronFinishDarwinCopy(paths Paths) (err error) {
+func (p *defaultProvisioner) provisionElectronFinishDarwinCopy(dir string, paths Paths) (err error) {
 	// Icon
-	var src, dst = paths.AppIconDarwinSrc(), filepath.Join(paths.ElectronDirectory(), "Electron.app", "Contents", "Resources", "electron.icns")
+	var src, dst = paths.AppIconDarwinSrc(), filepath.Join(dir, "Electron.app", "Contents", "Resources", "electron.icns")
 	if src != "" {
 		p.l.Debugf("Copying %s to %s", src, dst)
 		if err = astikit.CopyFile(context.Background(), dst, src, astikit.LocalCopyFileFunc); err != nil {
@@ -274,13 +1300,13 @@ func (p *defaultProvisioner) provisionElectronFinishDarwinCopy(paths Paths) (err
 }
 
 // provisionElectronFinishDarwinReplace makes the proper replacements in the proper darwin files
-func (p *defaultProvisioner) provisionElectronFinishDarwinReplace(appName string, paths Paths) (err error) {
+func (p *defaultProvisioner) provisionElectronFinishDarwinReplace(appName, dir string) (err error) {
 	for _, path := range []string{
-		filepath.Join(paths.electronDirectory, "Electron.app", "Contents", "Info.plist"),
-		filepath.Join(paths.electronDirectory, "Electron.app", "Contents", "Frameworks", "Electron Helper.app", "Contents", "Info.plist"),
-		filepath.Join(paths.electronDirectory, "Electron.app", "Contents", "Frameworks", "Electron Helper (Renderer).app", "Contents", "Info.plist"),
-		filepath.Join(paths.electronDirectory, "Electron.app", "Contents", "Frameworks", "Electron Helper (Plugin).app", "Contents", "Info.plist"),
-		filepath.Join(paths.electronDirectory, "Electron.app", "Contents", "Frameworks", "Electron Helper (GPU).app", "Contents", "Info.plist"),
+		filepath.Join(dir, "Electron.app", "Contents", "Info.plist"),
+		filepath.Join(dir, "Electron.app", "Contents", "Frameworks", "Electron Helper.app", "Contents", "Info.plist"),
+		filepath.Join(dir, "Electron.app", "Contents", "Frameworks", "Electron Helper (Renderer).app", "Contents", "Info.plist"),
+		filepath.Join(dir, "Electron.app", "Contents", "Frameworks", "Electron Helper (Plugin).app", "Contents", "Info.plist"),
+		filepath.Join(dir, "Electron.app", "Contents", "Frameworks", "Electron Helper (GPU).app", "Contents", "Info.plist"),
 	} {
 		// Log
 		p.l.Debugf("Replacing in %s", path)
@@ -319,16 +1345,16 @@ type rename struct {
 }
 
 // provisionElectronFinishDarwinRename renames the proper darwin folders
-func (p *defaultProvisioner) provisionElectronFinishDarwinRename(appName string, paths Paths) (err error) {
-	var appDirectory = filepath.Join(paths.electronDirectory, appName+".app")
+func (p *defaultProvisioner) provisionElectronFinishDarwinRename(appName, dir string) (err error) {
+	var appDirectory = filepath.Join(dir, appName+".app")
 	var frameworksDirectory = filepath.Join(appDirectory, "Contents", "Frameworks")
 	var helper = filepath.Join(frameworksDirectory, appName+" Helper.app")
 	var helperRenderer = filepath.Join(frameworksDirectory, appName+" Helper (Renderer).app")
 	var helperPlugin = filepath.Join(frameworksDirectory, appName+" Helper (Plugin).app")
 	var helperGPU = filepath.Join(frameworksDirectory, appName+" Helper (GPU).app")
 	for _, r := range []rename{
-		{src: filepath.Join(paths.electronDirectory, "Electron.app"), dst: appDirectory},
-		{src: filepath.Join(appDirectory, "Contents", "MacOS", "Electron"), dst: paths.AppExecutable()},
+		{src: filepath.Join(dir, "Electron.app"), dst: appDirectory},
+		{src: filepath.Join(appDirectory, "Contents", "MacOS", "Electron"), dst: filepath.Join(appDirectory, "Contents", "MacOS", appName)},
 		{src: filepath.Join(frameworksDirectory, "Electron Helper.app"), dst: filepath.Join(helper)},
 		{src: filepath.Join(frameworksDirectory, "Electron Helper (Renderer).app"), dst: filepath.Join(helperRenderer)},
 		{src: filepath.Join(frameworksDirectory, "Electron Helper (Plugin).app"), dst: filepath.Join(helperPlugin)},
@@ -349,12 +1375,199 @@ func (p *defaultProvisioner) provisionElectronFinishDarwinRename(appName string,
 	return
 }
 
+// provisionElectronFinishLinux finishes provisioning electron for Linux systems
+func (p *defaultProvisioner) provisionElectronFinishLinux(appName, dir string) (err error) {
+	// Log
+	p.l.Debug("Finishing provisioning electron for linux system")
+
+	// Custom app name
+	if n := LinuxExecutableName(appName); n != "electron" {
+		var src, dst = filepath.Join(dir, "electron"), filepath.Join(dir, n)
+		p.l.Debugf("Renaming %s into %s", src, dst)
+		if err = os.Rename(src, dst); err != nil {
+			return fmt.Errorf("renaming %s into %s failed: %w", src, dst, err)
+		}
+	}
+
+	// Make sure binaries are executable even if the archive didn't store permissions
+	for _, path := range []string{
+		filepath.Join(dir, LinuxExecutableName(appName)),
+		filepath.Join(dir, "chrome-sandbox"),
+		filepath.Join(dir, "chrome_crashpad_handler"),
+	} {
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Lstat(path); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return fmt.Errorf("stating %s failed: %w", path, err)
+		}
+
+		// Only regular files are processed
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+
+		// Chmod
+		p.l.Debugf("Making %s executable", path)
+		if err = os.Chmod(path, fi.Mode().Perm()|0111); err != nil {
+			return fmt.Errorf("chmoding %s failed: %w", path, err)
+		}
+	}
+	return
+}
+
+// installLinuxDesktopEntry writes the app desktop entry and installs its default icon in the XDG data directory
+func (p *defaultProvisioner) installLinuxDesktopEntry(appName string, paths Paths) (err error) {
+	// Get data directory
+	var dataDirectory string
+	if dataDirectory, err = xdgDataHome(); err != nil {
+		return fmt.Errorf("getting xdg data home failed: %w", err)
+	}
+	var name = LinuxExecutableName(appName)
+
+	// Install icon
+	var icon string
+	if paths.AppIconDefaultSrc() != "" {
+		// Get icon directory
+		var iconDirectory string
+		if iconDirectory, err = linuxIconDirectory(paths.AppIconDefaultSrc()); err != nil {
+			return fmt.Errorf("getting icon directory failed: %w", err)
+		}
+
+		// Read
+		var b []byte
+		if b, err = ioutil.ReadFile(paths.AppIconDefaultSrc()); err != nil {
+			return fmt.Errorf("reading %s failed: %w", paths.AppIconDefaultSrc(), err)
+		}
+
+		// Copy
+		var dst = filepath.Join(dataDirectory, "icons", "hicolor", iconDirectory, "apps", name+filepath.Ext(paths.AppIconDefaultSrc()))
+		if err = p.writeFileIfChanged(dst, b); err != nil {
+			return fmt.Errorf("copying %s to %s failed: %w", paths.AppIconDefaultSrc(), dst, err)
+		}
+		icon = name
+	}
+
+	// Get exec
+	var exec = p.o.LinuxDesktopEntry.Exec
+	if exec == "" {
+		if exec, err = os.Executable(); err != nil {
+			return fmt.Errorf("getting executable failed: %w", err)
+		}
+	}
+
+	// Create content
+	if appName == "" {
+		appName = "Electron"
+	}
+	var lines = []string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=" + linuxDesktopEntryEscape(appName),
+		"Exec=" + linuxDesktopEntryEscape(linuxDesktopEntryQuote(exec)),
+		"Terminal=false",
+		"StartupWMClass=" + linuxDesktopEntryEscape(appName),
+	}
+	if p.o.LinuxDesktopEntry.Comment != "" {
+		lines = append(lines, "Comment="+linuxDesktopEntryEscape(p.o.LinuxDesktopEntry.Comment))
+	}
+	if icon != "" {
+		lines = append(lines, "Icon="+icon)
+	}
+	if len(p.o.LinuxDesktopEntry.Categories) > 0 {
+		lines = append(lines, "Categories="+strings.Join(p.o.LinuxDesktopEntry.Categories, ";")+";")
+	}
+
+	// Write
+	var dst = filepath.Join(dataDirectory, "applications", name+".desktop")
+	if err = p.writeFileIfChanged(dst, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+		return fmt.Errorf("writing desktop entry %s failed: %w", dst, err)
+	}
+	return
+}
+
+// writeFileIfChanged writes a file unless it already has the same content, so that desktop environments watching it
+// are not notified on every start
+func (p *defaultProvisioner) writeFileIfChanged(path string, b []byte) (err error) {
+	// Content hasn't changed
+	if c, errRead := ioutil.ReadFile(path); errRead == nil && bytes.Equal(c, b) {
+		return
+	}
+
+	// Make sure the directory exists
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(path), err)
+	}
+
+	// Write
+	p.l.Debugf("Writing %s", path)
+	if err = ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("writing %s failed: %w", path, err)
+	}
+	return
+}
+
+// xdgDataHome returns the XDG data home directory
+// https://specifications.freedesktop.org/basedir-spec/latest/
+func xdgDataHome() (string, error) {
+	return xdgDirectory("XDG_DATA_HOME", ".local", "share")
+}
+
+// linuxIconDirectory returns the icon theme directory an icon should be installed in, based on its size
+func linuxIconDirectory(path string) (d string, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		// Open
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return "", fmt.Errorf("opening %s failed: %w", path, err)
+		}
+		defer f.Close()
+
+		// Decode config
+		var c image.Config
+		if c, err = png.DecodeConfig(f); err != nil {
+			return "", fmt.Errorf("decoding png config of %s failed: %w", path, err)
+		}
+		return fmt.Sprintf("%dx%d", c.Width, c.Height), nil
+	case ".svg":
+		return "scalable", nil
+	default:
+		return "", fmt.Errorf("icon %s must be either a .png or a .svg", path)
+	}
+}
+
+// linuxDesktopEntryQuote quotes an argument of the Exec key of a desktop entry if needed
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/exec-variables.html
+func linuxDesktopEntryQuote(i string) string {
+	// Literal percent signs must be doubled since they introduce field codes
+	i = strings.Replace(i, "%", "%%", -1)
+	if !strings.ContainsAny(i, " \t\n\"'\\><~|&;$*?#()`") {
+		return i
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(i) + `"`
+}
+
+// linuxDesktopEntryEscape escapes a string value of a desktop entry
+// Values are unescaped before the Exec key is unquoted, therefore a literal backslash in a quoted argument ends up
+// written as 4 backslashes
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/value-types.html
+func linuxDesktopEntryEscape(i string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(i)
+}
+
 // Disembedder is a functions that allows to disembed data from a path
 type Disembedder func(src string) ([]byte, error)
 
 // NewDisembedderProvisioner creates a provisioner that can provision based on embedded data
 func NewDisembedderProvisioner(d Disembedder, pathAstilectron, pathElectron string, l astikit.StdLogger) Provisioner {
 	dp := &defaultProvisioner{l: astikit.AdaptStdLogger(l)}
+	dp.unzipperAstilectron, dp.unzipperElectron = dp.pathsUnzippers()
+	dp.sourceAstilectron = func(p Paths) string { return pathAstilectron }
+	dp.sourceElectron = func(p Paths) string { return pathElectron }
 	dp.moverAstilectron = func(ctx context.Context, p Paths) (closeFunc func() error, err error) {
 		if err = Disembed(ctx, dp.l, d, pathAstilectron, p.AstilectronDownloadDst()); err != nil {
 			return nil, fmt.Errorf("disembedding %s into %s failed: %w", pathAstilectron, p.AstilectronDownloadDst(), err)
@@ -381,3 +1594,118 @@ func NewDisembedderProvisioner(d Disembedder, pathAstilectron, pathElectron stri
 	}
 	return dp
 }
+
+// ReaderOpener is a function that opens a reader on an archive
+// If the returned reader implements io.ReaderAt and either has a Stat() or a Seek() method, the archive is unzipped
+// directly from it. Otherwise it is streamed to the vendor directory before being unzipped.
+type ReaderOpener func() (io.ReadCloser, error)
+
+// NewReaderProvisioner creates a provisioner that can provision based on archives streamed from readers
+func NewReaderProvisioner(astilectron, electron ReaderOpener, l astikit.StdLogger, o ProvisionerOptions) Provisioner {
+	return newReaderProvisioner(astilectron, electron, l, o)
+}
+
+func newReaderProvisioner(astilectron, electron ReaderOpener, l astikit.StdLogger, o ProvisionerOptions) (dp *defaultProvisioner) {
+	dp = &defaultProvisioner{
+		l: astikit.AdaptStdLogger(l),
+		o: o,
+	}
+	dp.unzipperAstilectron = dp.readerUnzipper(astilectron, func(p Paths) (string, string) {
+		return p.AstilectronDownloadDst(), p.AstilectronUnzipSrc()
+	})
+	dp.unzipperElectron = dp.readerUnzipper(electron, func(p Paths) (string, string) {
+		return p.ElectronDownloadDst(), p.ElectronUnzipSrc()
+	})
+	return
+}
+
+// readerUnzipper returns an unzipper unzipping the archive returned by the opener
+func (p *defaultProvisioner) readerUnzipper(o ReaderOpener, fn func(p Paths) (downloadDst, unzipSrc string)) unzipper {
+	return func(ctx context.Context, paths Paths, dst string, skip func(path string) bool) (err error) {
+		// Open
+		var rc io.ReadCloser
+		if rc, err = o(); err != nil {
+			return fmt.Errorf("opening reader failed: %w", err)
+		}
+		defer rc.Close()
+
+		// Unzip directly from the reader
+		downloadDst, unzipSrc := fn(paths)
+		if ra, ok := rc.(io.ReaderAt); ok {
+			if size, ok := readerSize(rc); ok {
+				internalPath := strings.TrimPrefix(strings.TrimPrefix(unzipSrc, downloadDst), string(os.PathSeparator))
+				if err = unzipReader(ctx, p.l, ra, size, internalPath, dst, skip); err != nil {
+					return fmt.Errorf("unzipping reader into %s failed: %w", dst, err)
+				}
+				return
+			}
+		}
+
+		// Stream the archive to disk
+		if err = p.stream(ctx, rc, downloadDst); err != nil {
+			return fmt.Errorf("streaming into %s failed: %w", downloadDst, err)
+		}
+		defer func() {
+			p.l.Debugf("Removing %s", downloadDst)
+			if err := os.Remove(downloadDst); err != nil {
+				// Only log the error
+				p.l.Error(fmt.Errorf("removing %s failed: %w", downloadDst, err))
+			}
+		}()
+
+		// Unzip
+		if err = unzip(ctx, p.l, unzipSrc, dst, skip); err != nil {
+			return fmt.Errorf("unzipping %s into %s failed: %w", unzipSrc, dst, err)
+		}
+		return
+	}
+}
+
+// readerSize returns the size of a reader if it can be computed without reading it
+func readerSize(r io.Reader) (int64, bool) {
+	if s, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if fi, err := s.Stat(); err == nil {
+			return fi.Size(), true
+		}
+	}
+	if s, ok := r.(io.Seeker); ok {
+		size, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err = s.Seek(0, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return size, true
+	}
+	return 0, false
+}
+
+// stream copies a reader into a dst and cleans up on failure
+func (p *defaultProvisioner) stream(ctx context.Context, r io.Reader, dst string) (err error) {
+	// Make sure directory exists
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(dst), err)
+	}
+
+	// Create dst
+	var f *os.File
+	p.l.Debugf("Streaming into %s", dst)
+	if f, err = os.Create(dst); err != nil {
+		return fmt.Errorf("creating %s failed: %w", dst, err)
+	}
+
+	// Copy
+	if _, err = astikit.Copy(ctx, f, r); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return fmt.Errorf("copying into %s failed: %w", dst, err)
+	}
+
+	// Close
+	if err = f.Close(); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("closing %s failed: %w", dst, err)
+	}
+	return
+}
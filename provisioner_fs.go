@@ -0,0 +1,25 @@
+//go:build go1.16
+// +build go1.16
+
+package astilectron
+
+import (
+	"io"
+	"io/fs"
+
+	"github.com/asticode/go-astikit"
+)
+
+// NewFSProvisioner creates a provisioner that can provision based on archives stored in a fs.FS such as an embed.FS
+// Archives are unzipped directly from the fs.FS whenever possible so that they are never loaded entirely in memory
+func NewFSProvisioner(fsys fs.FS, pathAstilectron, pathElectron string, l astikit.StdLogger, o ProvisionerOptions) Provisioner {
+	dp := newReaderProvisioner(fsOpener(fsys, pathAstilectron), fsOpener(fsys, pathElectron), l, o)
+	dp.sourceAstilectron = func(p Paths) string { return pathAstilectron }
+	dp.sourceElectron = func(p Paths) string { return pathElectron }
+	return dp
+}
+
+// fsOpener returns a reader opener opening a path in a fs.FS
+func fsOpener(fsys fs.FS, path string) ReaderOpener {
+	return func() (io.ReadCloser, error) { return fsys.Open(path) }
+}
@@ -0,0 +1,42 @@
+//go:build go1.16
+// +build go1.16
+
+package astilectron
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFSProvisioner(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron-0.35.1")
+	var m ProvisionMetrics
+	pvb := NewFSProvisioner(os.DirFS("testdata/provisioner"), "astilectron/disembedder.zip", "electron/linux/electron.zip", nil, ProvisionerOptions{OnMetrics: func(i ProvisionMetrics) { m = i }})
+
+	// Test dry run
+	err = NewFSProvisioner(os.DirFS("testdata/provisioner"), "astilectron/disembedder.zip", "electron/linux/electron.zip", nil, ProvisionerOptions{Mode: ProvisionModeDryRun}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	_, err = os.Stat(p.AstilectronDirectory())
+	assert.True(t, os.IsNotExist(err))
+
+	// Test provision
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	assert.True(t, m.Total > 0)
+
+	// Archives should have been unzipped directly
+	_, err = os.Stat(p.AstilectronDownloadDst())
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(p.ElectronDownloadDst())
+	assert.True(t, os.IsNotExist(err))
+}
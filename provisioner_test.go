@@ -2,23 +2,39 @@ package astilectron
 
 import (
 	"context"
+	"errors"
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"io/ioutil"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func testProvisionerSuccessful(t *testing.T, p Paths, osName, arch, versionAstilectron, versionElectron string) {
+	p.resolveAppExecutable()
 	_, err := os.Stat(p.AstilectronApplication())
 	assert.NoError(t, err)
 	_, err = os.Stat(p.AppExecutable())
 	assert.NoError(t, err)
-	b, err := ioutil.ReadFile(p.ProvisionStatus())
+	s, err := ReadProvisionStatus(p)
 	assert.NoError(t, err)
-	assert.Equal(t, "{\"astilectron\":{\"version\":\""+versionAstilectron+"\"},\"electron\":{\""+provisionStatusElectronKey(osName, arch)+"\":{\"version\":\""+versionElectron+"\"}}}\n", string(b))
+	if assert.NotNil(t, s.Astilectron) {
+		assert.Equal(t, versionAstilectron, s.Astilectron.Version)
+		assert.NotNil(t, s.Astilectron.InstalledAt)
+		assert.Contains(t, s.Astilectron.Files, "main.js")
+	}
+	if e, ok := s.Electron[provisionStatusElectronKey(osName, arch)]; assert.True(t, ok) {
+		assert.Equal(t, versionElectron, e.Version)
+		assert.NotNil(t, e.InstalledAt)
+		assert.NotEmpty(t, e.Files)
+	}
+	v, err := VerifyProvisioning(p)
+	assert.NoError(t, err)
+	assert.True(t, v.Valid())
 }
 
 func TestDefaultProvisioner(t *testing.T) {
@@ -34,15 +50,18 @@ func TestDefaultProvisioner(t *testing.T) {
 	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
 	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
 	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
-	err = newDefaultProvisioner(nil).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
 	assert.NoError(t, err)
 	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	fi, err := os.Stat(p.AppExecutable())
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0111), fi.Mode().Perm()&0111)
 
 	// Test nothing happens if provision status is up to date
 	mh.e = true
 	os.Remove(p.AstilectronDownloadDst())
 	os.Remove(p.ElectronDownloadDst())
-	err = newDefaultProvisioner(nil).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
 	assert.NoError(t, err)
 	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
 
@@ -54,7 +73,7 @@ func TestDefaultProvisioner(t *testing.T) {
 	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
 	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
 	p.electronDownloadSrc = s.URL + "/provisioner/electron/windows"
-	err = newDefaultProvisioner(nil).Provision(context.Background(), "", "windows", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "windows", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
 	assert.NoError(t, err)
 	testProvisionerSuccessful(t, *p, "windows", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
 
@@ -65,7 +84,7 @@ func TestDefaultProvisioner(t *testing.T) {
 	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
 	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
 	p.electronDownloadSrc = s.URL + "/provisioner/electron/darwin"
-	err = newDefaultProvisioner(nil).Provision(context.Background(), "", "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
 	assert.NoError(t, err)
 	testProvisionerSuccessful(t, *p, "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
 
@@ -80,7 +99,7 @@ func TestDefaultProvisioner(t *testing.T) {
 	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
 	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
 	p.electronDownloadSrc = s.URL + "/provisioner/electron/darwin"
-	err = newDefaultProvisioner(nil).Provision(context.Background(), o.AppName, "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), o.AppName, "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
 	assert.NoError(t, err)
 	testProvisionerSuccessful(t, *p, "darwin", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
 	// Rename
@@ -103,6 +122,233 @@ func TestDefaultProvisioner(t *testing.T) {
 	b, err = ioutil.ReadFile(filepath.Join(p.ElectronDirectory(), o.AppName+".app", "Contents", "Frameworks", o.AppName+" Helper.app", "Contents", "Info.plist"))
 	assert.NoError(t, err)
 	assert.Equal(t, "<string>"+o.AppName+" Test</string>", string(b))
+
+	// Test linux with custom app name + desktop entry
+	os.RemoveAll(o.BaseDirectoryPath)
+	o.AppName = ""
+	o.AppIconDefaultPath = filepath.Join(wd, "testdata", "provisioner", "icon.png")
+	p, err = newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	o.AppName = "Test app"
+	p, err = newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "electron"), p.AppExecutable())
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+	v, err := VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-app"}, v.Electron.Missing)
+	var dh = filepath.Join(wd, o.BaseDirectoryPath, "xdg")
+	dhb := os.Getenv("XDG_DATA_HOME")
+	os.Setenv("XDG_DATA_HOME", dh)
+	defer os.Setenv("XDG_DATA_HOME", dhb)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{LinuxDesktopEntry: &LinuxDesktopEntry{
+		Categories: []string{"Utility", "Development"},
+		Comment:    "Test comment",
+		Exec:       "/path/to/test app",
+	}}).Provision(context.Background(), o.AppName, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	_, err = os.Stat(filepath.Join(p.ElectronDirectory(), "electron"))
+	assert.True(t, os.IsNotExist(err))
+	b, err = ioutil.ReadFile(filepath.Join(dh, "applications", "test-app.desktop"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[Desktop Entry]\nType=Application\nName=Test app\nExec=\"/path/to/test app\"\nTerminal=false\nStartupWMClass=Test app\nComment=Test comment\nIcon=test-app\nCategories=Utility;Development;\n", string(b))
+	_, err = os.Stat(filepath.Join(dh, "icons", "hicolor", "16x16", "apps", "test-app.png"))
+	assert.NoError(t, err)
+	p.resolveAppExecutable()
+	assert.Equal(t, filepath.Join(p.ElectronDirectory(), "test-app"), p.AppExecutable())
+
+	// Test desktop entry is not rewritten if it hasn't changed
+	var mt = time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(filepath.Join(dh, "applications", "test-app.desktop"), mt, mt))
+	err = newDefaultProvisioner(nil, ProvisionerOptions{LinuxDesktopEntry: &LinuxDesktopEntry{
+		Categories: []string{"Utility", "Development"},
+		Comment:    "Test comment",
+		Exec:       "/path/to/test app",
+	}}).Provision(context.Background(), o.AppName, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	fi, err = os.Stat(filepath.Join(dh, "applications", "test-app.desktop"))
+	assert.NoError(t, err)
+	assert.Equal(t, mt, fi.ModTime())
+}
+
+func TestLinuxDesktopEntryQuote(t *testing.T) {
+	assert.Equal(t, "/path/to/app", linuxDesktopEntryEscape(linuxDesktopEntryQuote("/path/to/app")))
+	assert.Equal(t, `/path/to/100%%`, linuxDesktopEntryEscape(linuxDesktopEntryQuote("/path/to/100%")))
+	assert.Equal(t, `"/path/to/test app"`, linuxDesktopEntryEscape(linuxDesktopEntryQuote("/path/to/test app")))
+	assert.Equal(t, `"/path/to/a\\\\b"`, linuxDesktopEntryEscape(linuxDesktopEntryQuote(`/path/to/a\b`)))
+	assert.Equal(t, `"/path/to/\\"\\$a\\" %%b"`, linuxDesktopEntryEscape(linuxDesktopEntryQuote(`/path/to/"$a" %b`)))
+}
+
+func TestDefaultProvisioner_Replace(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	var mh = &mockedHandler{}
+	var s = httptest.NewServer(mh)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+
+	// Test leftovers of a previous failed provisioning are ignored and cleaned up
+	assert.NoError(t, os.MkdirAll(filepath.Join(p.ElectronDirectory(), "old"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(p.ElectronDirectory()+".tmp", "leftover"), 0755))
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	for _, path := range []string{
+		filepath.Join(p.ElectronDirectory(), "old"),
+		p.ElectronDirectory() + ".tmp",
+		p.ElectronDirectory() + ".old",
+		p.ProvisionLock(),
+		p.ProvisionStatus() + ".tmp",
+	} {
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), path)
+	}
+
+	// Test package is provisioned again if its directory is missing despite an up to date provision status
+	assert.NoError(t, os.RemoveAll(p.ElectronDirectory()))
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+
+	// Test provisioning waits for the lock
+	fl, err := lockFile(context.Background(), &logger{}, p.ProvisionLock(), time.Second)
+	assert.NoError(t, err)
+	err = newDefaultProvisioner(nil, ProvisionerOptions{LockTimeout: 200 * time.Millisecond}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.Error(t, err)
+	assert.NoError(t, fl.unlock())
+}
+
+func TestVerifyProvisioning(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	var mh = &mockedHandler{}
+	var s = httptest.NewServer(mh)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+
+	// Test not provisioned
+	v, err := VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.True(t, v.Astilectron.NotProvisioned)
+	assert.True(t, v.Electron.NotProvisioned)
+	assert.False(t, v.Valid())
+
+	// Provision
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	ps, err := ReadProvisionStatus(*p)
+	assert.NoError(t, err)
+	assert.Equal(t, s.URL+"/provisioner/astilectron", ps.Astilectron.Source)
+	assert.Equal(t, s.URL+"/provisioner/electron/linux", ps.Electron[provisionStatusElectronKey("linux", "amd64")].Source)
+
+	// Test missing file
+	assert.NoError(t, os.Remove(p.AppExecutable()))
+	v, err = VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.True(t, v.Astilectron.Valid())
+	assert.Equal(t, ProvisionPackageVerification{Missing: []string{"electron"}}, v.Electron)
+
+	// Test modified file with the same size
+	b, err := ioutil.ReadFile(p.AstilectronApplication())
+	assert.NoError(t, err)
+	b[0]++
+	assert.NoError(t, ioutil.WriteFile(p.AstilectronApplication(), b, 0644))
+	v, err = VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.Equal(t, ProvisionPackageVerification{Modified: []string{"main.js"}}, v.Astilectron)
+
+	// Test only the broken package is repaired, modified files with the same size are only detected when hashing
+	mh.e = true
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.Error(t, err)
+	mh.e = false
+	err = newDefaultProvisioner(nil, ProvisionerOptions{}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	_, err = os.Stat(p.AppExecutable())
+	assert.NoError(t, err)
+	v, err = VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.False(t, v.Astilectron.Valid())
+	assert.True(t, v.Electron.Valid())
+	err = newDefaultProvisioner(nil, ProvisionerOptions{VerifyHashes: true}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+}
+
+func TestDefaultProvisioner_Modes(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	var mh = &mockedHandler{}
+	var s = httptest.NewServer(mh)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron")
+	p.astilectronDownloadSrc = s.URL + "/provisioner/astilectron"
+	p.electronDownloadSrc = s.URL + "/provisioner/electron/linux"
+
+	// Test dry run doesn't touch disk
+	var pl ProvisionPlan
+	err = newDefaultProvisioner(nil, ProvisionerOptions{Mode: ProvisionModeDryRun, OnPlan: func(i ProvisionPlan) { pl = i }}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	_, err = os.Stat(o.BaseDirectoryPath)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, &ProvisionPlanPackage{
+		Archive:      p.AstilectronDownloadDst(),
+		Directory:    p.AstilectronDirectory(),
+		Download:     true,
+		Name:         "Astilectron",
+		Source:       p.AstilectronDownloadSrc(),
+		Verification: ProvisionPackageVerification{NotProvisioned: true},
+		Version:      DefaultVersionAstilectron,
+	}, pl.Astilectron)
+	assert.NotNil(t, pl.Electron)
+
+	// Test offline fails fast with the list of what is missing
+	err = newDefaultProvisioner(nil, ProvisionerOptions{Mode: ProvisionModeOffline}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	var e *ProvisionOfflineError
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Len(t, e.Packages, 2)
+	}
+	_, err = os.Stat(p.AstilectronDirectory())
+	assert.True(t, os.IsNotExist(err))
+
+	// Test offline succeeds if archives are present
+	mh.e = true
+	assert.NoError(t, os.MkdirAll(p.VendorDirectory(), 0755))
+	assert.NoError(t, astikit.CopyFile(context.Background(), p.AstilectronDownloadDst(), "testdata/provisioner/astilectron/astilectron.zip", astikit.LocalCopyFileFunc))
+	assert.NoError(t, astikit.CopyFile(context.Background(), p.ElectronDownloadDst(), "testdata/provisioner/electron/linux/electron.zip", astikit.LocalCopyFileFunc))
+	err = newDefaultProvisioner(nil, ProvisionerOptions{Mode: ProvisionModeOffline}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+
+	// Test archives provided by the caller are kept
+	_, err = os.Stat(p.AstilectronDownloadDst())
+	assert.NoError(t, err)
+	_, err = os.Stat(p.ElectronDownloadDst())
+	assert.NoError(t, err)
+
+	// Test dry run reports nothing once provisioned
+	err = newDefaultProvisioner(nil, ProvisionerOptions{Mode: ProvisionModeDryRun, OnPlan: func(i ProvisionPlan) { pl = i }}).Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	assert.True(t, pl.Empty())
 }
 
 func TestNewDisembedderProvisioner(t *testing.T) {
@@ -160,3 +406,182 @@ func TestRemoveDownloadDst(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 }
+
+// mockedReadCloser is a mocked read closer that can only be read sequentially
+type mockedReadCloser struct {
+	io.Reader
+}
+
+// Close implements the io.Closer interface
+func (m mockedReadCloser) Close() error { return nil }
+
+func TestNewReaderProvisioner(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron-0.35.1")
+	pvb := NewReaderProvisioner(func() (io.ReadCloser, error) {
+		f, err := os.Open("testdata/provisioner/astilectron/disembedder.zip")
+		return mockedReadCloser{Reader: f}, err
+	}, func() (io.ReadCloser, error) {
+		return os.Open("testdata/provisioner/electron/linux/electron.zip")
+	}, nil, ProvisionerOptions{})
+
+	// Test provision
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+
+	// Streamed archives should have been removed
+	_, err = os.Stat(p.AstilectronDownloadDst())
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(p.ElectronDownloadDst())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPruneProvisioning(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath(), VersionAstilectron: "1.0.0", VersionElectron: "2.0.0"}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := NewPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(p.VendorDirectory(), "astilectron.old"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(p.VendorDirectory(), "electron-linux-amd64"), 0755))
+	for _, n := range []string{"astilectron-v0.1.0.zip", "astilectron-v1.0.0.zip", "electron-linux-amd64-v1.0.0.zip", "electron-linux-amd64-v2.0.0.zip", "electron-windows-amd64-v3.0.0.zip", "electron-windows-amd64-v4.0.0.zip", "other.zip"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(p.VendorDirectory(), n), []byte("body"), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(p.ProvisionStatus(), []byte(`{"electron":{"windows-amd64":{"version":"3.0.0"}}}`), 0644))
+
+	// Prune
+	removed, err := PruneProvisioning(context.Background(), nil, *p, 0)
+	assert.NoError(t, err)
+	for i := range removed {
+		removed[i] = filepath.Base(removed[i])
+	}
+	assert.Equal(t, []string{"astilectron-v0.1.0.zip", "astilectron.old", "electron-linux-amd64-v1.0.0.zip", "electron-windows-amd64-v4.0.0.zip"}, removed)
+	fis, err := ioutil.ReadDir(p.VendorDirectory())
+	assert.NoError(t, err)
+	var ns []string
+	for _, fi := range fis {
+		ns = append(ns, fi.Name())
+	}
+	assert.Equal(t, []string{"astilectron-v1.0.0.zip", "electron-linux-amd64", "electron-linux-amd64-v2.0.0.zip", "electron-windows-amd64-v3.0.0.zip", "other.zip", "status.json"}, ns)
+}
+
+func TestDefaultProvisioner_Incremental(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	p.astilectronUnzipSrc = filepath.Join(p.astilectronDownloadDst, "astilectron-0.35.1")
+	var m ProvisionMetrics
+	pvb := newReaderProvisioner(func() (io.ReadCloser, error) {
+		return os.Open("testdata/provisioner/astilectron/disembedder.zip")
+	}, func() (io.ReadCloser, error) {
+		return os.Open("testdata/provisioner/unzip/linux.zip")
+	}, nil, ProvisionerOptions{OnMetrics: func(i ProvisionMetrics) { m = i }})
+
+	// Test both packages are provisioned
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	if assert.NotNil(t, m.Astilectron) && assert.NotNil(t, m.Electron) {
+		assert.Equal(t, 0, m.Electron.Reused)
+		assert.True(t, m.Electron.Total >= m.Electron.Extraction)
+	}
+	assert.True(t, m.Total > 0)
+
+	// Test only missing files are extracted again
+	assert.NoError(t, os.Remove(filepath.Join(p.ElectronDirectory(), "libffmpeg.so.1")))
+	m = ProvisionMetrics{}
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.NoError(t, err)
+	testProvisionerSuccessful(t, *p, "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron)
+	assert.Nil(t, m.Astilectron)
+	if assert.NotNil(t, m.Electron) {
+		assert.Equal(t, 3, m.Electron.Reused)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(p.ElectronDirectory(), "libffmpeg.so.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ffmpeg", string(b))
+}
+
+func TestDefaultProvisioner_Errors(t *testing.T) {
+	// Init
+	var o = Options{BaseDirectoryPath: mockedTempPath()}
+	defer os.RemoveAll(o.BaseDirectoryPath)
+	p, err := newPaths("linux", "amd64", o)
+	assert.NoError(t, err)
+	var errElectron = errors.New("electron error")
+	var electronFailed = make(chan struct{})
+	pvb := newReaderProvisioner(func() (io.ReadCloser, error) {
+		<-electronFailed
+		time.Sleep(10 * time.Millisecond)
+		return nil, context.Canceled
+	}, func() (io.ReadCloser, error) {
+		defer close(electronFailed)
+		return nil, errElectron
+	}, nil, ProvisionerOptions{})
+
+	// Test the first error is not masked by the other package's cancellation
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *p)
+	assert.True(t, errors.Is(err, errElectron))
+	assert.False(t, errors.Is(err, context.Canceled))
+
+	// Test the provision status is written
+	_, err = os.Stat(p.ProvisionStatus())
+	assert.NoError(t, err)
+}
+
+func TestMigrateVendorDirectory(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	pl, err := newPaths("linux", "amd64", Options{BaseDirectoryPath: filepath.Join(d, "legacy")})
+	assert.NoError(t, err)
+	pl.astilectronUnzipSrc = filepath.Join(pl.astilectronDownloadDst, "astilectron-0.35.1")
+	pvb := newReaderProvisioner(func() (io.ReadCloser, error) {
+		return os.Open("testdata/provisioner/astilectron/disembedder.zip")
+	}, func() (io.ReadCloser, error) {
+		return os.Open("testdata/provisioner/unzip/linux.zip")
+	}, nil, ProvisionerOptions{})
+	err = pvb.Provision(context.Background(), "", "linux", "amd64", DefaultVersionAstilectron, DefaultVersionElectron, *pl)
+	assert.NoError(t, err)
+	s, err := ReadProvisionStatus(*pl)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(pl.VendorDirectory(), "electron-linux-amd64-v1.0.0.zip"), []byte("body"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(pl.VendorDirectory(), "astilectron.old"), 0755))
+	p, err := newPaths("linux", "amd64", Options{BaseDirectoryPath: filepath.Join(d, "data")})
+	assert.NoError(t, err)
+	p.cacheDirectory = filepath.Join(d, "cache")
+	p.legacyVendorDirectory = pl.VendorDirectory()
+
+	// Test legacy vendor directory is locked
+	fl, err := lockFile(context.Background(), &logger{}, pl.ProvisionLock(), 0)
+	assert.NoError(t, err)
+	assert.Error(t, migrateVendorDirectory(context.Background(), &logger{}, *p, 10*time.Millisecond))
+	_, err = os.Stat(p.ProvisionStatus())
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, fl.unlock())
+
+	// Migrate
+	assert.NoError(t, migrateVendorDirectory(context.Background(), &logger{}, *p, 0))
+	sm, err := ReadProvisionStatus(*p)
+	assert.NoError(t, err)
+	assert.Equal(t, s, sm)
+	v, err := VerifyProvisioning(*p)
+	assert.NoError(t, err)
+	assert.True(t, v.Valid())
+	_, err = os.Stat(filepath.Join(p.CacheDirectory(), "electron-linux-amd64-v1.0.0.zip"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(p.VendorDirectory(), "astilectron.old"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(pl.ProvisionStatus())
+	assert.True(t, os.IsNotExist(err))
+
+	// Already migrated
+	assert.NoError(t, migrateVendorDirectory(context.Background(), &logger{}, *p, 0))
+}
@@ -33,3 +33,20 @@ type RectangleOptions struct {
 	PositionOptions
 	SizeOptions
 }
+
+// rectangle converts rectangle options into a rectangle, missing values default to 0
+func (o RectangleOptions) rectangle() (r Rectangle) {
+	if o.X != nil {
+		r.X = *o.X
+	}
+	if o.Y != nil {
+		r.Y = *o.Y
+	}
+	if o.Height != nil {
+		r.Height = *o.Height
+	}
+	if o.Width != nil {
+		r.Width = *o.Width
+	}
+	return
+}
@@ -0,0 +1,372 @@
+package astilectron
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Var
+var (
+	regexpElectronVersion = regexp.MustCompile(`v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)`)
+	systemElectronTimeout = 5 * time.Second
+)
+
+// SystemElectronOptions represents options to use an electron installed on the system instead of provisioning it
+type SystemElectronOptions struct {
+	// Paths looked into before PATH and well-known locations
+	Paths []string
+	// Range the electron version must be in, such as ">=11.0.0 <13.0.0", "^11.2.0" or "~11.4.0". Ranges separated
+	// by "||" are alternatives. Defaults to the major version of VersionElectron.
+	Range string
+	// If true, Start fails when no compatible electron is found instead of provisioning it
+	Required bool
+}
+
+// SystemElectronCandidate represents an electron binary that has been looked at
+type SystemElectronCandidate struct {
+	Err     error
+	Path    string
+	Version string
+}
+
+// SystemElectronError is returned when no compatible electron has been found on the system
+type SystemElectronError struct {
+	Candidates []SystemElectronCandidate
+	Range      string
+}
+
+// Error implements the error interface
+func (e SystemElectronError) Error() string {
+	if len(e.Candidates) == 0 {
+		return fmt.Sprintf("astilectron: no electron found on the system, supported range is %s", e.Range)
+	}
+	var ss []string
+	for _, c := range e.Candidates {
+		ss = append(ss, fmt.Sprintf("%s: %s", c.Path, c.Err))
+	}
+	return fmt.Sprintf("astilectron: no compatible electron found on the system, supported range is %s: %s", e.Range, strings.Join(ss, ", "))
+}
+
+// systemElectronRange returns the range an electron version must be in
+func systemElectronRange(o SystemElectronOptions, versionElectron string) string {
+	if o.Range != "" {
+		return o.Range
+	}
+	var major = strings.SplitN(versionElectron, ".", 2)[0]
+	if m, err := strconv.Atoi(major); err == nil {
+		return fmt.Sprintf(">=%d.0.0 <%d.0.0", m, m+1)
+	}
+	return "=" + versionElectron
+}
+
+// FindSystemElectron looks for an electron installed on the system whose version is in the supported range, first in
+// the provided paths, then in PATH and finally in well-known locations
+func FindSystemElectron(ctx context.Context, os, versionElectron string, o SystemElectronOptions) (c SystemElectronCandidate, err error) {
+	// Parse range
+	var r = systemElectronRange(o, versionElectron)
+	var vr versionRange
+	if vr, err = parseVersionRange(r); err != nil {
+		err = fmt.Errorf("parsing range %s failed: %w", r, err)
+		return
+	}
+
+	// Loop through candidates
+	var e = SystemElectronError{Range: r}
+	var done = make(map[string]bool)
+	for _, p := range append(append([]string{}, o.Paths...), systemElectronCandidates(os, versionElectron)...) {
+		// Resolve path
+		var ok bool
+		if p, ok = resolveSystemElectron(p); !ok || done[p] {
+			continue
+		}
+		done[p] = true
+
+		// Probe
+		c = SystemElectronCandidate{Path: p}
+		if c.Version, c.Err = probeElectronVersion(ctx, p); c.Err == nil && !vr.contains(c.Version) {
+			c.Err = fmt.Errorf("version %s is not in range %s", c.Version, r)
+		}
+
+		// Candidate is compatible
+		if c.Err == nil {
+			return
+		}
+		e.Candidates = append(e.Candidates, c)
+	}
+	err = e
+	c = SystemElectronCandidate{}
+	return
+}
+
+// systemElectronCandidates returns the paths and executable names electron is usually installed at
+func systemElectronCandidates(os, versionElectron string) (ps []string) {
+	var major = strings.SplitN(versionElectron, ".", 2)[0]
+	switch os {
+	case "darwin":
+		ps = []string{
+			"electron",
+			"/Applications/Electron.app/Contents/MacOS/Electron",
+			"/usr/local/lib/node_modules/electron/dist/Electron.app/Contents/MacOS/Electron",
+			"/opt/homebrew/lib/node_modules/electron/dist/Electron.app/Contents/MacOS/Electron",
+		}
+	case "linux":
+		ps = []string{
+			"electron" + major,
+			"electron",
+			"/usr/lib/electron" + major + "/electron",
+			"/usr/lib/electron/electron",
+			"/usr/lib64/electron" + major + "/electron",
+			"/usr/lib64/electron/electron",
+			"/usr/local/lib/node_modules/electron/dist/electron",
+			"/usr/lib/node_modules/electron/dist/electron",
+			"/snap/bin/electron",
+		}
+	case "windows":
+		ps = []string{"electron.exe"}
+	}
+	return
+}
+
+// resolveSystemElectron returns the absolute path of an electron binary if it exists. Names without directory are
+// looked for in PATH.
+func resolveSystemElectron(p string) (string, bool) {
+	// Look in PATH
+	if !strings.ContainsRune(p, filepath.Separator) && !strings.ContainsRune(p, '/') {
+		v, err := exec.LookPath(p)
+		if err != nil {
+			return "", false
+		}
+		p = v
+	}
+
+	// Resolve symlinks so that the same binary is probed only once
+	if v, err := filepath.EvalSymlinks(p); err == nil {
+		p = v
+	}
+
+	// Stat
+	fi, err := os.Stat(p)
+	if err != nil || fi.IsDir() {
+		return "", false
+	}
+	return p, true
+}
+
+// probeElectronVersion executes electron --version and parses its output
+func probeElectronVersion(ctx context.Context, path string) (v string, err error) {
+	// Create context
+	ctx, cancel := context.WithTimeout(ctx, systemElectronTimeout)
+	defer cancel()
+
+	// Execute
+	var b []byte
+	if b, err = exec.CommandContext(ctx, path, "--version").Output(); err != nil {
+		err = fmt.Errorf("executing %s --version failed: %w", path, err)
+		return
+	}
+
+	// Parse
+	var m = regexpElectronVersion.FindStringSubmatch(strings.TrimSpace(string(b)))
+	if len(m) < 2 {
+		err = fmt.Errorf("parsing version in %q failed", strings.TrimSpace(string(b)))
+		return
+	}
+	return m[1], nil
+}
+
+// useSystemElectron makes paths point to an electron installed on the system, which is not provisioned
+func (p *Paths) useSystemElectron(path string) {
+	p.appExecutable = path
+	p.appExecutableFallback = ""
+	p.appExecutableResolved = path
+	p.electronDirectory = ""
+	p.electronDownloadDst = ""
+	p.electronDownloadSHA256 = ""
+	p.electronDownloadSrc = ""
+	p.electronUnzipSrc = ""
+}
+
+// version represents a semver version
+type version struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+// parseVersion parses a semver version, missing minor and patch numbers default to 0
+func parseVersion(i string) (v version, err error) {
+	// Split prerelease and ignore build metadata
+	i = strings.TrimPrefix(strings.TrimSpace(i), "v")
+	if idx := strings.Index(i, "+"); idx >= 0 {
+		i = i[:idx]
+	}
+	if idx := strings.Index(i, "-"); idx >= 0 {
+		v.prerelease = i[idx+1:]
+		i = i[:idx]
+	}
+
+	// Parse numbers
+	var items = strings.Split(i, ".")
+	if len(items) > 3 {
+		err = fmt.Errorf("version %s is invalid", i)
+		return
+	}
+	for idx, ptr := range []*int{&v.major, &v.minor, &v.patch} {
+		if idx >= len(items) {
+			break
+		}
+		if *ptr, err = strconv.Atoi(items[idx]); err != nil {
+			err = fmt.Errorf("version %s is invalid: %w", i, err)
+			return
+		}
+	}
+	return
+}
+
+// compare returns -1, 0 or 1 if v is respectively lower than, equal to or greater than o
+func (v version) compare(o version) int {
+	for _, c := range [][2]int{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if c[0] < c[1] {
+			return -1
+		} else if c[0] > c[1] {
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == o.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case o.prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.prerelease, o.prerelease)
+}
+
+// comparePrerelease compares prereleases identifier by identifier as semver does: numeric identifiers are compared
+// numerically and have a lower precedence than alphanumeric ones, and a larger set of identifiers has a higher
+// precedence if all the preceding ones are equal
+func comparePrerelease(a, b string) int {
+	var as, bs = strings.Split(a, "."), strings.Split(b, ".")
+	for idx := 0; idx < len(as) && idx < len(bs); idx++ {
+		an, errA := strconv.Atoi(as[idx])
+		bn, errB := strconv.Atoi(bs[idx])
+		switch {
+		case errA == nil && errB == nil:
+			if an < bn {
+				return -1
+			} else if an > bn {
+				return 1
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case as[idx] < bs[idx]:
+			return -1
+		case as[idx] > bs[idx]:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// isPrereleaseOf checks whether v is a prerelease of the release o
+func (v version) isPrereleaseOf(o version) bool {
+	return v.prerelease != "" && o.prerelease == "" && v.major == o.major && v.minor == o.minor && v.patch == o.patch
+}
+
+// versionComparator represents a version comparison such as ">=11.0.0"
+type versionComparator struct {
+	operator string
+	version  version
+}
+
+// versionRange represents alternatives of comparators that must all be satisfied
+type versionRange [][]versionComparator
+
+// parseVersionRange parses a version range
+func parseVersionRange(i string) (r versionRange, err error) {
+	for _, alternative := range strings.Split(i, "||") {
+		var cs []versionComparator
+		for _, item := range strings.Fields(alternative) {
+			// Get operator
+			var c versionComparator
+			for _, o := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+				if strings.HasPrefix(item, o) {
+					c.operator = o
+					break
+				}
+			}
+
+			// Parse version
+			if c.version, err = parseVersion(strings.TrimPrefix(item, c.operator)); err != nil {
+				err = fmt.Errorf("parsing %s failed: %w", item, err)
+				return
+			}
+
+			// Expand caret and tilde
+			switch c.operator {
+			case "^":
+				cs = append(cs, versionComparator{operator: ">=", version: c.version}, versionComparator{operator: "<", version: version{major: c.version.major + 1}})
+			case "~":
+				cs = append(cs, versionComparator{operator: ">=", version: c.version}, versionComparator{operator: "<", version: version{major: c.version.major, minor: c.version.minor + 1}})
+			default:
+				cs = append(cs, c)
+			}
+		}
+		if len(cs) == 0 {
+			err = fmt.Errorf("range %s is invalid", i)
+			return
+		}
+		r = append(r, cs)
+	}
+	return
+}
+
+// contains checks whether a version is in the range
+func (r versionRange) contains(i string) bool {
+	v, err := parseVersion(i)
+	if err != nil {
+		return false
+	}
+	for _, cs := range r {
+		var ok = true
+		for _, c := range cs {
+			var cmp = v.compare(c.version)
+			switch c.operator {
+			case ">=":
+				ok = cmp >= 0
+			case ">":
+				ok = cmp > 0
+			case "<=":
+				ok = cmp <= 0
+			case "<":
+				// Prereleases of an excluded upper bound are excluded as well, so that "<12.0.0" doesn't contain
+				// "12.0.0-beta.1"
+				ok = cmp < 0 && !v.isPrereleaseOf(c.version)
+			default:
+				ok = cmp == 0
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package astilectron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockedSystemElectron(t *testing.T, dir, name, output string) string {
+	var p = filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(p, []byte(fmt.Sprintf("#!/bin/sh\necho %q\n", output)), 0755))
+	return p
+}
+
+func TestVersionRange(t *testing.T) {
+	for _, c := range []struct {
+		r  string
+		in []string
+		ex []string
+	}{
+		{r: ">=11.0.0 <12.0.0", in: []string{"11.0.0", "11.4.3", "11.4.3+build.1"}, ex: []string{"10.9.9", "12.0.0", "11.0.0-beta.1", "12.0.0-beta.1"}},
+		{r: "^11.2", in: []string{"11.2.0", "11.9.1"}, ex: []string{"11.1.9", "12.0.0", "12.0.0-alpha.1"}},
+		{r: "~11.4.1", in: []string{"11.4.1", "11.4.9"}, ex: []string{"11.4.0", "11.5.0"}},
+		{r: "11.4.3 || >=13", in: []string{"11.4.3", "13.0.0", "14.1.0"}, ex: []string{"11.4.2", "12.0.0"}},
+		{r: ">11.0.0-beta.1 <=11.0.0", in: []string{"11.0.0-beta.2", "11.0.0-beta.10", "11.0.0-beta.1.1", "11.0.0-rc.1", "11.0.0"}, ex: []string{"11.0.0-beta.1", "11.0.0-beta", "11.0.0-alpha.2", "11.0.1", "invalid"}},
+		{r: ">=11.0.0-beta.2 <11.0.0-beta.10", in: []string{"11.0.0-beta.2", "11.0.0-beta.9"}, ex: []string{"11.0.0-beta.10", "11.0.0-beta.11", "11.0.0"}},
+	} {
+		r, err := parseVersionRange(c.r)
+		assert.NoError(t, err)
+		for _, v := range c.in {
+			assert.True(t, r.contains(v), "%s should be in %s", v, c.r)
+		}
+		for _, v := range c.ex {
+			assert.False(t, r.contains(v), "%s should not be in %s", v, c.r)
+		}
+	}
+	_, err := parseVersionRange(">=a.b")
+	assert.Error(t, err)
+	_, err = parseVersionRange(">=11 ||")
+	assert.Error(t, err)
+	assert.Equal(t, ">=11.0.0 <12.0.0", systemElectronRange(SystemElectronOptions{}, "11.4.3"))
+	assert.Equal(t, "^12", systemElectronRange(SystemElectronOptions{Range: "^12"}, "11.4.3"))
+}
+
+func TestFindSystemElectron(t *testing.T) {
+	// Init
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts can't be executed on windows")
+	}
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	var old = mockedSystemElectron(t, d, "old", "v10.1.0")
+	var invalid = mockedSystemElectron(t, d, "invalid", "invalid")
+	var valid = mockedSystemElectron(t, d, "valid", "v11.4.3")
+
+	// Compatible
+	c, err := FindSystemElectron(context.Background(), "", "11.4.3", SystemElectronOptions{Paths: []string{filepath.Join(d, "missing"), old, invalid, valid}})
+	assert.NoError(t, err)
+	assert.Equal(t, "11.4.3", c.Version)
+	p, _ := filepath.EvalSymlinks(valid)
+	assert.Equal(t, p, c.Path)
+
+	// Incompatible
+	_, err = FindSystemElectron(context.Background(), "", "11.4.3", SystemElectronOptions{Paths: []string{old, invalid, valid}, Range: "^12"})
+	var e SystemElectronError
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, "^12", e.Range)
+		assert.Len(t, e.Candidates, 3)
+		assert.Equal(t, "11.4.3", e.Candidates[2].Version)
+	}
+}
+
+func TestAstilectron_SystemElectron(t *testing.T) {
+	// Init
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts can't be executed on windows")
+	}
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.MkdirAll(d, 0755))
+	var valid = mockedSystemElectron(t, d, "valid", "v"+DefaultVersionElectron)
+	var o = Options{BaseDirectoryPath: d, SystemElectron: &SystemElectronOptions{Paths: []string{valid}}}
+
+	// Compatible
+	a, err := New(nil, o)
+	assert.NoError(t, err)
+	assert.NoError(t, a.systemElectron())
+	p, _ := filepath.EvalSymlinks(valid)
+	assert.Equal(t, p, a.paths.AppExecutable())
+	assert.Equal(t, "", a.paths.ElectronDirectory())
+	assert.Equal(t, "", a.paths.ElectronUnzipSrc())
+
+	// Fallback
+	o.SystemElectron = &SystemElectronOptions{Paths: []string{valid}, Range: "^1"}
+	a, err = New(nil, o)
+	assert.NoError(t, err)
+	var e = a.paths.AppExecutable()
+	assert.NoError(t, a.systemElectron())
+	assert.Equal(t, e, a.paths.AppExecutable())
+
+	// Required
+	o.SystemElectron.Required = true
+	a, err = New(nil, o)
+	assert.NoError(t, err)
+	assert.Error(t, a.systemElectron())
+
+	// Incompatible custom electron
+	o.CustomElectronPath = valid
+	o.SystemElectron = &SystemElectronOptions{Range: "^1"}
+	a, err = New(nil, o)
+	assert.NoError(t, err)
+	assert.Error(t, a.systemElectron())
+}
@@ -86,13 +86,27 @@ type Window struct {
 	*object
 	callbackIdentifier *identifier
 	l                  astikit.SeverityLogger
-	m                  sync.Mutex // Locks o
+	m                  sync.Mutex // Locks o, s and url
 	o                  *WindowOptions
 	onMessageOnce      sync.Once
+	s                  WindowState
 	Session            *Session
 	url                *stdUrl.URL
 }
 
+// WindowState represents a snapshot of the state of a window
+// It's kept in sync with the events Electron sends
+type WindowState struct {
+	AlwaysOnTop bool
+	Bounds      Rectangle
+	Closed      bool
+	Focused     bool
+	Fullscreen  bool
+	Maximized   bool
+	Minimized   bool
+	Shown       bool
+}
+
 // WindowOptions represents window options
 // We must use pointers since GO doesn't handle optional fields whereas NodeJS does. Use astikit.BoolPtr, astikit.IntPtr or astikit.StrPtr
 // to fill the struct
@@ -122,6 +136,7 @@ type WindowOptions struct {
 	MinWidth               *int                  `json:"minWidth,omitempty"`
 	Modal                  *bool                 `json:"modal,omitempty"`
 	Movable                *bool                 `json:"movable,omitempty"`
+	Opacity                *float64              `json:"opacity,omitempty"` // Between 0 (fully transparent) and 1 (fully opaque)
 	Resizable              *bool                 `json:"resizable,omitempty"`
 	Show                   *bool                 `json:"show,omitempty"`
 	SkipTaskbar            *bool                 `json:"skipTaskbar,omitempty"`
@@ -130,6 +145,7 @@ type WindowOptions struct {
 	TrafficLightPosition   *TrafficLightPosition `json:"trafficLightPosition,omitempty"`
 	Transparent            *bool                 `json:"transparent,omitempty"`
 	UseContentSize         *bool                 `json:"useContentSize,omitempty"`
+	VisibleOnAllWorkspaces *bool                 `json:"visibleOnAllWorkspaces,omitempty"`
 	WebPreferences         *WebPreferences       `json:"webPreferences,omitempty"`
 	Width                  *int                  `json:"width,omitempty"`
 	X                      *int                  `json:"x,omitempty"`
@@ -140,6 +156,7 @@ type WindowOptions struct {
 	Custom     *WindowCustomOptions `json:"custom,omitempty"`
 	Load       *WindowLoadOptions   `json:"load,omitempty"`
 	Proxy      *WindowProxyOptions  `json:"proxy,omitempty"`
+	State      *WindowStateOptions  `json:"-"`
 }
 
 // WindowAppDetails represents window app details
@@ -241,6 +258,21 @@ func newWindow(ctx context.Context, l astikit.SeverityLogger, o Options, p Paths
 		wo.Title = astikit.StrPtr(o.AppName)
 	}
 
+	// Headless windows are rendered offscreen
+	if o.Headless.offscreen() {
+		if wo.WebPreferences == nil {
+			wo.WebPreferences = &WebPreferences{}
+		}
+		wo.WebPreferences.Offscreen = astikit.BoolPtr(true)
+	}
+
+	// Init state
+	w.s = WindowState{
+		AlwaysOnTop: wo.AlwaysOnTop != nil && *wo.AlwaysOnTop,
+		Fullscreen:  wo.Fullscreen != nil && *wo.Fullscreen,
+		Shown:       wo.Show != nil && *wo.Show,
+	}
+
 	// Make sure the window's context is cancelled once the closed event is received
 	w.On(EventNameWindowEventClosed, func(e Event) (deleteListener bool) {
 		w.cancel()
@@ -299,14 +331,48 @@ func newWindow(ctx context.Context, l astikit.SeverityLogger, o Options, p Paths
 	w.On(EventNameWindowEventUnmaximize, updateBoundsFunc(w))
 	w.On(EventNameWindowEventWillMove, updateBoundsFunc(w))
 
+	// Keep the state in sync
+	for _, n := range []string{
+		EventNameWindowEventAlwaysOnTopChanged,
+		EventNameWindowEventBlur,
+		EventNameWindowEventClosed,
+		EventNameWindowEventDidFinishLoad,
+		EventNameWindowEventEnterFullScreen,
+		EventNameWindowEventFocus,
+		EventNameWindowEventHide,
+		EventNameWindowEventLeaveFullScreen,
+		EventNameWindowEventMaximize,
+		EventNameWindowEventMinimize,
+		EventNameWindowEventMove,
+		EventNameWindowEventMoved,
+		EventNameWindowEventResize,
+		EventNameWindowEventResizeContent,
+		EventNameWindowEventRestore,
+		EventNameWindowEventShow,
+		EventNameWindowEventUnmaximize,
+		EventNameWindowEventWillMove,
+	} {
+		w.On(n, w.updateState)
+	}
+
+	// Parse url
+	if w.url, err = parseWindowURL(url); err != nil {
+		err = fmt.Errorf("parsing url failed: %w", err)
+		return
+	}
+	return
+}
+
+// parseWindowURL parses an url, paths being converted to file urls
+func parseWindowURL(url string) (u *stdUrl.URL, err error) {
 	// Basic parse
-	if w.url, err = stdUrl.Parse(url); err != nil {
+	if u, err = stdUrl.Parse(url); err != nil {
 		err = fmt.Errorf("std parsing of url %s failed: %w", url, err)
 		return
 	}
 
 	// File
-	if w.url.Scheme == "" {
+	if u.Scheme == "" {
 		// Get absolute path
 		if url, err = filepath.Abs(url); err != nil {
 			err = fmt.Errorf("getting absolute path of %s failed: %w", url, err)
@@ -314,9 +380,8 @@ func newWindow(ctx context.Context, l astikit.SeverityLogger, o Options, p Paths
 		}
 
 		// Set url
-		w.url = &stdUrl.URL{Path: filepath.ToSlash(url), Scheme: "file"}
+		u = &stdUrl.URL{Path: filepath.ToSlash(url), Scheme: "file"}
 	}
-
 	return
 }
 
@@ -387,7 +452,10 @@ func (w *Window) Create() (err error) {
 	if err = w.ctx.Err(); err != nil {
 		return
 	}
-	_, err = synchronousEvent(w.ctx, w, w.w, Event{Name: EventNameWindowCmdCreate, SessionID: w.Session.id, TargetID: w.id, URL: w.url.String(), WindowOptions: w.o}, EventNameWindowEventDidFinishLoad)
+	w.m.Lock()
+	e := Event{Name: EventNameWindowCmdCreate, SessionID: w.Session.id, TargetID: w.id, URL: w.url.String(), WindowOptions: w.o}
+	w.m.Unlock()
+	_, err = synchronousEvent(w.ctx, w, w.w, e, EventNameWindowEventDidFinishLoad)
 	return
 }
 
@@ -658,6 +726,13 @@ func (w *Window) Show() (err error) {
 	return
 }
 
+// State returns a snapshot of the window state
+func (w *Window) State() WindowState {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.s
+}
+
 // Unmaximize unmaximize the window
 func (w *Window) Unmaximize() (err error) {
 	if err = w.ctx.Err(); err != nil {
@@ -678,3 +753,48 @@ func (w *Window) UpdateCustomOptions(o WindowCustomOptions) (err error) {
 	_, err = synchronousEvent(w.ctx, w, w.w, Event{WindowOptions: w.o, Name: EventNameWindowCmdUpdateCustomOptions, TargetID: w.id}, EventNameWindowEventUpdatedCustomOptions)
 	return
 }
+
+// updateState updates the state based on a window event
+func (w *Window) updateState(e Event) (deleteListener bool) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	// Update flags
+	switch e.Name {
+	case EventNameWindowEventAlwaysOnTopChanged:
+		if e.Enable != nil {
+			w.s.AlwaysOnTop = *e.Enable
+		}
+	case EventNameWindowEventBlur:
+		w.s.Focused = false
+	case EventNameWindowEventClosed:
+		w.s.Closed = true
+		w.s.Focused = false
+		w.s.Shown = false
+	case EventNameWindowEventEnterFullScreen:
+		w.s.Fullscreen = true
+	case EventNameWindowEventFocus:
+		w.s.Focused = true
+	case EventNameWindowEventHide:
+		w.s.Shown = false
+	case EventNameWindowEventLeaveFullScreen:
+		w.s.Fullscreen = false
+	case EventNameWindowEventMaximize:
+		w.s.Maximized = true
+		w.s.Minimized = false
+	case EventNameWindowEventMinimize:
+		w.s.Minimized = true
+	case EventNameWindowEventRestore:
+		w.s.Minimized = false
+	case EventNameWindowEventShow:
+		w.s.Shown = true
+	case EventNameWindowEventUnmaximize:
+		w.s.Maximized = false
+	}
+
+	// Update bounds
+	if e.Bounds != nil {
+		w.s.Bounds = e.Bounds.rectangle()
+	}
+	return
+}
@@ -0,0 +1,283 @@
+package astilectron
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// DefaultWindowStateDebounce is the default duration a window state change waits for other changes before being saved
+const DefaultWindowStateDebounce = 500 * time.Millisecond
+
+// Var
+var (
+	regexpWindowStateName = regexp.MustCompile("[^A-Za-z0-9._-]+")
+)
+
+// WindowStateOptions represents options to persist a window's state across launches
+type WindowStateOptions struct {
+	// Defaults to DefaultWindowStateDebounce
+	Debounce time.Duration
+	// Stable name the state is stored under, it must be unique among the app's windows
+	Name string
+}
+
+// windowSavedState represents the state of a window persisted across launches
+type windowSavedState struct {
+	Bounds     *RectangleOptions `json:"bounds,omitempty"` // Bounds of the window when it's neither maximized nor fullscreen
+	DisplayID  *int64            `json:"displayId,omitempty"`
+	Fullscreen bool              `json:"fullscreen,omitempty"`
+	Maximized  bool              `json:"maximized,omitempty"`
+}
+
+// windowStatePath returns the path the state of a window is stored at
+func windowStatePath(p Paths, name string) string {
+	return filepath.Join(p.DataDirectory(), "window-state", regexpWindowStateName.ReplaceAllString(name, "_")+".json")
+}
+
+// readWindowState reads the state of a window
+func readWindowState(path string) (s windowSavedState, err error) {
+	// Read
+	var b []byte
+	if b, err = ioutil.ReadFile(path); err != nil {
+		if !os.IsNotExist(err) {
+			err = fmt.Errorf("reading %s failed: %w", path, err)
+		}
+		return
+	}
+
+	// Unmarshal
+	if err = json.Unmarshal(b, &s); err != nil {
+		err = fmt.Errorf("unmarshaling %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+// writeWindowState writes the state of a window atomically
+func writeWindowState(path string, s windowSavedState) (err error) {
+	// Marshal
+	var b []byte
+	if b, err = json.Marshal(s); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+
+	// Make sure the directory exists
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(path), err)
+		return
+	}
+
+	// Write
+	var tmp = path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", tmp, err)
+		return
+	}
+
+	// Rename
+	if err = os.Rename(tmp, path); err != nil {
+		err = fmt.Errorf("renaming %s into %s failed: %w", tmp, path, err)
+		return
+	}
+	return
+}
+
+// restoreWindowState updates window options with the state saved during a previous launch
+// Its bounds are clamped to the work area of the display the window was in, or of the primary display if it's gone, so
+// that the window is never restored off-screen
+func restoreWindowState(s windowSavedState, o *WindowOptions, displays []*Display) {
+	// Bounds
+	if s.Bounds != nil && s.Bounds.X != nil && s.Bounds.Y != nil && s.Bounds.Width != nil && s.Bounds.Height != nil {
+		var r = Rectangle{
+			Position: Position{X: *s.Bounds.X, Y: *s.Bounds.Y},
+			Size:     Size{Height: *s.Bounds.Height, Width: *s.Bounds.Width},
+		}
+
+		// Get display
+		var found, primary *Display
+		for _, d := range displays {
+			if s.DisplayID != nil && d.ID() == *s.DisplayID {
+				found = d
+			}
+			if d.IsPrimary() {
+				primary = d
+			}
+		}
+		if found == nil {
+			found = primary
+		}
+
+		// Clamp
+		if found != nil {
+			r = clampRectangle(r, found.WorkArea())
+		}
+
+		// Update options
+		o.Center = nil
+		o.X, o.Y = astikit.IntPtr(r.X), astikit.IntPtr(r.Y)
+		o.Height, o.Width = astikit.IntPtr(r.Height), astikit.IntPtr(r.Width)
+	}
+
+	// Fullscreen
+	if s.Fullscreen {
+		o.Fullscreen = astikit.BoolPtr(true)
+	}
+}
+
+// clampRectangle makes sure a rectangle fits in an area
+func clampRectangle(r, area Rectangle) Rectangle {
+	if r.Width > area.Width {
+		r.Width = area.Width
+	}
+	if r.Height > area.Height {
+		r.Height = area.Height
+	}
+	if r.X < area.X {
+		r.X = area.X
+	} else if r.X+r.Width > area.X+area.Width {
+		r.X = area.X + area.Width - r.Width
+	}
+	if r.Y < area.Y {
+		r.Y = area.Y
+	} else if r.Y+r.Height > area.Y+area.Height {
+		r.Y = area.Y + area.Height - r.Height
+	}
+	return r
+}
+
+// windowStateTracker saves the state of a window when it changes
+type windowStateTracker struct {
+	debounce time.Duration
+	displays func() []*Display
+	l        astikit.SeverityLogger
+	m        sync.Mutex // Locks s and t
+	path     string
+	s        windowSavedState
+	t        *time.Timer
+}
+
+// newWindowStateTracker restores the state of a window and tracks its changes
+func newWindowStateTracker(l astikit.SeverityLogger, p Paths, w *Window, displays func() []*Display) *windowStateTracker {
+	// Create tracker
+	var o = w.o.State
+	t := &windowStateTracker{
+		debounce: o.Debounce,
+		displays: displays,
+		l:        l,
+		path:     windowStatePath(p, o.Name),
+	}
+	if t.debounce <= 0 {
+		t.debounce = DefaultWindowStateDebounce
+	}
+
+	// Restore
+	var err error
+	if t.s, err = readWindowState(t.path); err != nil && !os.IsNotExist(err) {
+		l.Error(fmt.Errorf("astilectron: reading window state failed: %w", err))
+	}
+	restoreWindowState(t.s, w.o, displays())
+
+	// Maximize once the window has been created
+	if t.s.Maximized {
+		w.On(EventNameWindowEventDidFinishLoad, func(e Event) (deleteListener bool) {
+			go func() {
+				if err := w.Maximize(); err != nil {
+					l.Error(fmt.Errorf("astilectron: maximizing window failed: %w", err))
+				}
+			}()
+			return true
+		})
+	}
+
+	// Track changes
+	for _, n := range []string{
+		EventNameWindowEventDidFinishLoad,
+		EventNameWindowEventEnterFullScreen,
+		EventNameWindowEventLeaveFullScreen,
+		EventNameWindowEventMaximize,
+		EventNameWindowEventMove,
+		EventNameWindowEventMoved,
+		EventNameWindowEventResize,
+		EventNameWindowEventUnmaximize,
+	} {
+		w.On(n, t.onEvent)
+	}
+
+	// Save pending changes once the window is closed
+	w.On(EventNameWindowEventClosed, func(e Event) (deleteListener bool) {
+		t.flush()
+		return true
+	})
+	return t
+}
+
+// onEvent updates the state based on a window event and schedules its saving
+func (t *windowStateTracker) onEvent(e Event) (deleteListener bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	// Update flags
+	switch e.Name {
+	case EventNameWindowEventEnterFullScreen:
+		t.s.Fullscreen = true
+	case EventNameWindowEventLeaveFullScreen:
+		t.s.Fullscreen = false
+	case EventNameWindowEventMaximize:
+		t.s.Maximized = true
+	case EventNameWindowEventUnmaximize:
+		t.s.Maximized = false
+	}
+
+	// Update bounds, only when they are the ones the window should be restored with
+	if e.Bounds != nil && !t.s.Maximized && !t.s.Fullscreen && e.Name != EventNameWindowEventEnterFullScreen {
+		t.s.Bounds = &RectangleOptions{
+			PositionOptions: PositionOptions{X: e.Bounds.X, Y: e.Bounds.Y},
+			SizeOptions:     SizeOptions{Height: e.Bounds.Height, Width: e.Bounds.Width},
+		}
+	}
+
+	// Schedule saving
+	if t.t != nil {
+		t.t.Stop()
+	}
+	t.t = time.AfterFunc(t.debounce, t.flush)
+	return
+}
+
+// flush saves the state
+func (t *windowStateTracker) flush() {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	// Cancel scheduled saving
+	if t.t != nil {
+		t.t.Stop()
+		t.t = nil
+	}
+
+	// Get display
+	t.s.DisplayID = nil
+	if b := t.s.Bounds; b != nil && b.X != nil && b.Y != nil && b.Width != nil && b.Height != nil {
+		var x, y = *b.X + *b.Width/2, *b.Y + *b.Height/2
+		for _, d := range t.displays() {
+			if r := d.Bounds(); x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height {
+				t.s.DisplayID = astikit.Int64Ptr(d.ID())
+				break
+			}
+		}
+	}
+
+	// Write
+	if err := writeWindowState(t.path, t.s); err != nil {
+		t.l.Error(fmt.Errorf("astilectron: writing window state failed: %w", err))
+	}
+}
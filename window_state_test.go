@@ -0,0 +1,99 @@
+package astilectron
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockedRectangleOptions(x, y, width, height int) *RectangleOptions {
+	return &RectangleOptions{
+		PositionOptions: PositionOptions{X: astikit.IntPtr(x), Y: astikit.IntPtr(y)},
+		SizeOptions:     SizeOptions{Height: astikit.IntPtr(height), Width: astikit.IntPtr(width)},
+	}
+}
+
+func TestClampRectangle(t *testing.T) {
+	var area = Rectangle{Position: Position{X: 0, Y: 20}, Size: Size{Height: 1000, Width: 1500}}
+	assert.Equal(t, Rectangle{Position: Position{X: 10, Y: 30}, Size: Size{Height: 100, Width: 200}}, clampRectangle(Rectangle{Position: Position{X: 10, Y: 30}, Size: Size{Height: 100, Width: 200}}, area))
+	assert.Equal(t, Rectangle{Position: Position{X: 1300, Y: 920}, Size: Size{Height: 100, Width: 200}}, clampRectangle(Rectangle{Position: Position{X: 2000, Y: 1500}, Size: Size{Height: 100, Width: 200}}, area))
+	assert.Equal(t, Rectangle{Position: Position{X: 0, Y: 20}, Size: Size{Height: 1000, Width: 1500}}, clampRectangle(Rectangle{Position: Position{X: -100, Y: 0}, Size: Size{Height: 2000, Width: 3000}}, area))
+}
+
+func TestRestoreWindowState(t *testing.T) {
+	// Init
+	var ds = []*Display{
+		newDisplay(&DisplayOptions{ID: astikit.Int64Ptr(1), Bounds: mockedRectangleOptions(0, 0, 1920, 1080), WorkArea: mockedRectangleOptions(0, 25, 1920, 1055)}, true),
+		newDisplay(&DisplayOptions{ID: astikit.Int64Ptr(2), Bounds: mockedRectangleOptions(1920, 0, 1280, 1024), WorkArea: mockedRectangleOptions(1920, 0, 1280, 1024)}, false),
+	}
+
+	// No state
+	o := &WindowOptions{Center: astikit.BoolPtr(true)}
+	restoreWindowState(windowSavedState{}, o, ds)
+	assert.Equal(t, &WindowOptions{Center: astikit.BoolPtr(true)}, o)
+
+	// Display is still there
+	o = &WindowOptions{Center: astikit.BoolPtr(true)}
+	restoreWindowState(windowSavedState{Bounds: mockedRectangleOptions(2000, 100, 800, 600), DisplayID: astikit.Int64Ptr(2), Fullscreen: true}, o, ds)
+	assert.Equal(t, &WindowOptions{Fullscreen: astikit.BoolPtr(true), Height: astikit.IntPtr(600), Width: astikit.IntPtr(800), X: astikit.IntPtr(2000), Y: astikit.IntPtr(100)}, o)
+
+	// Display geometry has changed
+	o = &WindowOptions{}
+	restoreWindowState(windowSavedState{Bounds: mockedRectangleOptions(2900, 900, 800, 600), DisplayID: astikit.Int64Ptr(2)}, o, ds)
+	assert.Equal(t, &WindowOptions{Height: astikit.IntPtr(600), Width: astikit.IntPtr(800), X: astikit.IntPtr(2400), Y: astikit.IntPtr(424)}, o)
+
+	// Display is gone
+	o = &WindowOptions{}
+	restoreWindowState(windowSavedState{Bounds: mockedRectangleOptions(4000, 100, 800, 600), DisplayID: astikit.Int64Ptr(3)}, o, ds)
+	assert.Equal(t, &WindowOptions{Height: astikit.IntPtr(600), Width: astikit.IntPtr(800), X: astikit.IntPtr(1120), Y: astikit.IntPtr(100)}, o)
+}
+
+func TestWindowStateTracker(t *testing.T) {
+	// Init
+	var d = mockedTempPath()
+	defer os.RemoveAll(d)
+	a, err := New(nil, Options{DataDirectoryPath: d})
+	assert.NoError(t, err)
+	defer a.Close()
+	a.displayPool.update(&EventDisplays{
+		All:     []*DisplayOptions{{ID: astikit.Int64Ptr(1), Bounds: mockedRectangleOptions(0, 0, 1920, 1080), WorkArea: mockedRectangleOptions(0, 0, 1920, 1080)}},
+		Primary: &DisplayOptions{ID: astikit.Int64Ptr(1)},
+	})
+	var o = &WindowStateOptions{Debounce: time.Millisecond, Name: "main/window"}
+	var p = windowStatePath(a.Paths(), o.Name)
+
+	// Track
+	w, err := a.NewWindow("http://test.com", &WindowOptions{Center: astikit.BoolPtr(true), State: o})
+	assert.NoError(t, err)
+	a.dispatcher.dispatch(Event{Name: EventNameWindowEventMoved, TargetID: w.id, Bounds: mockedRectangleOptions(10, 20, 800, 600)})
+	assert.Eventually(t, func() bool {
+		s, err := readWindowState(p)
+		return err == nil && s.Bounds != nil && *s.Bounds.X == 10
+	}, time.Second, 5*time.Millisecond)
+	s, err := readWindowState(p)
+	assert.NoError(t, err)
+	assert.Equal(t, windowSavedState{Bounds: mockedRectangleOptions(10, 20, 800, 600), DisplayID: astikit.Int64Ptr(1)}, s)
+
+	// Bounds of a maximized window are not saved
+	a.dispatcher.dispatch(Event{Name: EventNameWindowEventMaximize, TargetID: w.id, Bounds: mockedRectangleOptions(0, 0, 1920, 1080)})
+	assert.Eventually(t, func() bool {
+		s, err := readWindowState(p)
+		return err == nil && s.Maximized
+	}, time.Second, 5*time.Millisecond)
+	s, err = readWindowState(p)
+	assert.NoError(t, err)
+	assert.Equal(t, windowSavedState{Bounds: mockedRectangleOptions(10, 20, 800, 600), DisplayID: astikit.Int64Ptr(1), Maximized: true}, s)
+
+	// Restore
+	w, err = a.NewWindow("http://test.com", &WindowOptions{Center: astikit.BoolPtr(true), State: o})
+	assert.NoError(t, err)
+	assert.Nil(t, w.o.Center)
+	assert.Equal(t, 10, *w.o.X)
+	assert.Equal(t, 20, *w.o.Y)
+	assert.Equal(t, 800, *w.o.Width)
+	assert.Equal(t, 600, *w.o.Height)
+
+}
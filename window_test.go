@@ -3,6 +3,7 @@ package astilectron
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
@@ -121,6 +122,51 @@ func TestWindow_Actions(t *testing.T) {
 	testObjectAction(t, func() error { return w.ExecuteJavaScript("console.log('test');") }, w.object, wrt, "{\"name\":\""+EventNameWindowCmdWebContentsExecuteJavaScript+"\",\"targetID\":\""+w.id+"\",\"code\":\"console.log('test');\"}\n", EventNameWindowEventWebContentsExecutedJavaScript, nil)
 }
 
+func TestWindow_State(t *testing.T) {
+	// Init
+	a, err := New(nil, Options{})
+	assert.NoError(t, err)
+	defer a.Close()
+	w, err := a.NewWindow("http://test.com", &WindowOptions{AlwaysOnTop: astikit.BoolPtr(true), Show: astikit.BoolPtr(true)})
+	assert.NoError(t, err)
+	assert.Equal(t, WindowState{AlwaysOnTop: true, Shown: true}, w.State())
+
+	// Events
+	for _, e := range []Event{
+		{Name: EventNameWindowEventShow},
+		{Name: EventNameWindowEventFocus},
+		{Name: EventNameWindowEventMove, Bounds: &RectangleOptions{
+			PositionOptions: PositionOptions{X: astikit.IntPtr(1), Y: astikit.IntPtr(2)},
+			SizeOptions:     SizeOptions{Height: astikit.IntPtr(3), Width: astikit.IntPtr(4)},
+		}},
+		{Name: EventNameWindowEventMinimize},
+		{Name: EventNameWindowEventEnterFullScreen},
+		{Name: EventNameWindowEventAlwaysOnTopChanged, Enable: astikit.BoolPtr(false)},
+	} {
+		e.TargetID = w.id
+		w.updateState(e)
+	}
+	assert.Equal(t, WindowState{
+		Bounds:     Rectangle{Position: Position{X: 1, Y: 2}, Size: Size{Height: 3, Width: 4}},
+		Focused:    true,
+		Fullscreen: true,
+		Minimized:  true,
+		Shown:      true,
+	}, w.State())
+	for _, n := range []string{EventNameWindowEventRestore, EventNameWindowEventBlur, EventNameWindowEventLeaveFullScreen} {
+		w.updateState(Event{Name: n, TargetID: w.id})
+	}
+	assert.Equal(t, WindowState{
+		Bounds: Rectangle{Position: Position{X: 1, Y: 2}, Size: Size{Height: 3, Width: 4}},
+		Shown:  true,
+	}, w.State())
+
+	// Listeners
+	a.dispatcher.dispatch(Event{Name: EventNameWindowEventClosed, TargetID: w.id})
+	assert.Eventually(t, func() bool { return w.State().Closed }, time.Second, 5*time.Millisecond)
+	assert.False(t, w.State().Shown)
+}
+
 func TestWindow_OnLogin(t *testing.T) {
 	a, err := New(nil, Options{})
 	assert.NoError(t, err)